@@ -6,7 +6,6 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -19,14 +18,17 @@ import (
 	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/supervise"
 )
 
 const (
-	layerName     = "caddy"
-	xcaddyVersion = "v0.4.5"
+	layerName            = "caddy"
+	defaultXCaddyVersion = "v0.4.5"
+	xcaddyVersionEnv     = "BP_CADDY_XCADDY_VERSION"
+	pluginsEnv           = "BP_CADDY_PLUGINS"
 )
 
-var caddyPlugins = []string{
+var defaultCaddyPlugins = []string{
 	"github.com/ggicci/caddy-jwt",
 }
 
@@ -34,14 +36,54 @@ func main() {
 	packit.Run(detect, build)
 }
 
-func detect(packit.DetectContext) (packit.DetectResult, error) {
-	return packit.DetectResult{}, nil
+func detect(context packit.DetectContext) (packit.DetectResult, error) {
+	config, _, err := supervise.LoadConfig(context.WorkingDir)
+	if err != nil {
+		return packit.DetectResult{}, err
+	}
+
+	if supervise.ComponentDisabled(config.Disable, layerName) {
+		return packit.DetectResult{}, packit.Fail.WithMessage("caddy is disabled by the Supervise config or %s", supervise.DisableEnv)
+	}
+
+	// xcaddy is run through pkgx's go toolchain, so pkgx is only needed at build time.
+	return packit.DetectResult{
+		Plan: packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: layerName},
+			},
+			Requires: []packit.BuildPlanRequirement{
+				{Name: "pkgx", Metadata: map[string]interface{}{"build": true}},
+			},
+		},
+	}, nil
 }
 
 func build(context packit.BuildContext) (packit.BuildResult, error) {
-	plugins := append([]string(nil), caddyPlugins...)
+	config, source, err := supervise.LoadConfig(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	settings := config.Caddy
+	if settings.XCaddyVersion == "" {
+		settings.XCaddyVersion = defaultXCaddyVersion
+	}
+	if settings.Plugins == nil {
+		settings.Plugins = defaultCaddyPlugins
+	}
+	supervise.OverrideString(xcaddyVersionEnv, &settings.XCaddyVersion)
+	supervise.OverrideList(pluginsEnv, &settings.Plugins)
+
+	xcaddyVersion := settings.XCaddyVersion
+	plugins := append([]string(nil), settings.Plugins...)
 	sort.Strings(plugins)
 
+	supervise.PrintConfig(source, [][2]string{
+		{"caddy.xcaddy_version", xcaddyVersion},
+		{"caddy.plugins", strings.Join(plugins, ", ")},
+	})
+
 	metadataHash := sha256.Sum256([]byte(xcaddyVersion + ":" + strings.Join(plugins, ",")))
 	buildHash := hex.EncodeToString(metadataHash[:])
 
@@ -65,7 +107,8 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 					return packit.BuildResult{}, err
 				}
 
-				if err := writeSBOM(layer.Path, buildHash, plugins, cachedCaddyVersion); err != nil {
+				layer.SBOM, err = caddySBOM(context.BuildpackInfo, layer.Path, caddyPath, cachedCaddyVersion)
+				if err != nil {
 					return packit.BuildResult{}, err
 				}
 
@@ -134,7 +177,8 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 		"uri":               archiveURL,
 	}
 
-	if err := writeSBOM(layer.Path, buildHash, plugins, caddyVersion); err != nil {
+	layer.SBOM, err = caddySBOM(context.BuildpackInfo, layer.Path, caddyPath, caddyVersion)
+	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
@@ -143,30 +187,6 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 	}, nil
 }
 
-func writeSBOM(layerPath, buildHash string, plugins []string, version string) error {
-	sbom := map[string]interface{}{
-		"name": "caddy",
-		"metadata": map[string]interface{}{
-			"build_hash":     buildHash,
-			"xcaddy_version": xcaddyVersion,
-			"plugins":        strings.Join(plugins, ","),
-			"version":        version,
-		},
-	}
-
-	sbomData, err := json.MarshalIndent(sbom, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal SBOM: %w", err)
-	}
-
-	sbomPath := filepath.Join(layerPath, "sbom.json")
-	if err := os.WriteFile(sbomPath, sbomData, 0o644); err != nil {
-		return fmt.Errorf("failed to write SBOM file: %w", err)
-	}
-
-	return nil
-}
-
 func download(url string) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+)
+
+const caddyModule = "github.com/caddyserver/caddy/v2"
+
+// caddySBOM builds CycloneDX, SPDX and Syft documents listing the caddy binary
+// and every Go module compiled into it, as reported by the embedded build info
+// (the same data `go version -m` prints).
+func caddySBOM(info packit.BuildpackInfo, layerPath, caddyPath, caddyVersion string) (packit.SBOMFormats, error) {
+	build, err := buildinfo.ReadFile(caddyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read caddy build info: %w", err)
+	}
+
+	checksum, err := sbom.FileSHA256(caddyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read caddy binary: %w", err)
+	}
+
+	location, err := filepath.Rel(layerPath, caddyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve caddy binary location: %w", err)
+	}
+
+	subject := sbom.Component{Name: "caddy", SHA256: checksum, Type: "binary", Location: location}
+	if version := strings.Fields(caddyVersion); len(version) > 0 {
+		subject.Version = version[0]
+	}
+	subject.PURL = golangPURL(caddyModule, subject.Version)
+
+	components := []sbom.Component{
+		{
+			Name:    "stdlib",
+			Version: build.GoVersion,
+			PURL:    golangPURL("stdlib", build.GoVersion),
+			Type:    "go-module",
+		},
+	}
+
+	for _, dep := range build.Deps {
+		module := dep
+		if dep.Replace != nil {
+			module = dep.Replace
+		}
+
+		// The caddy module itself is the SBOM subject, not one of its components.
+		if module.Path == caddyModule {
+			continue
+		}
+
+		components = append(components, sbom.Component{
+			Name:    module.Path,
+			Version: module.Version,
+			PURL:    golangPURL(module.Path, module.Version),
+			Type:    "go-module",
+		})
+	}
+
+	return sbom.Formats(info, subject, components)
+}
+
+func golangPURL(path, version string) string {
+	if version == "" {
+		return "pkg:golang/" + path
+	}
+
+	return fmt.Sprintf("pkg:golang/%s@%s", path, url.PathEscape(version))
+}
@@ -0,0 +1,13 @@
+module github.com/supervise-dev/buildpack/internal
+
+go 1.25.1
+
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/paketo-buildpacks/packit/v2 v2.25.1
+)
+
+require (
+	github.com/Masterminds/semver/v3 v3.4.0 // indirect
+	github.com/pelletier/go-toml v1.9.5 // indirect
+)
@@ -0,0 +1,276 @@
+// Package sbom writes the CycloneDX, SPDX and Syft documents the Supervise
+// buildpacks attach to their layers. Each buildpack describes its layer as a
+// subject component and the components it contains.
+package sbom
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	cycloneDXExtension = "cdx.json"
+	spdxExtension      = "spdx.json"
+	syftExtension      = "syft.json"
+	syftSchemaVersion  = "16.0.34"
+)
+
+// Component is a package or file recorded in the documents.
+type Component struct {
+	Name     string
+	Version  string
+	PURL     string
+	SHA256   string
+	Type     string // Syft package type, e.g. "go-module" or "binary"
+	Location string // path of the component inside the layer, if any
+}
+
+// Formats builds the three documents for subject and the components it
+// depends on.
+func Formats(info packit.BuildpackInfo, subject Component, components []Component) (packit.SBOMFormats, error) {
+	cdx, err := json.MarshalIndent(cycloneDXDocument(info, subject, components), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CycloneDX SBOM: %w", err)
+	}
+
+	spdx, err := json.MarshalIndent(spdxDocument(info, subject, components), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SPDX SBOM: %w", err)
+	}
+
+	syft, err := json.MarshalIndent(syftDocument(info, subject, components), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Syft SBOM: %w", err)
+	}
+
+	return packit.SBOMFormats{
+		{Extension: cycloneDXExtension, Content: bytes.NewReader(cdx)},
+		{Extension: spdxExtension, Content: bytes.NewReader(spdx)},
+		{Extension: syftExtension, Content: bytes.NewReader(syft)},
+	}, nil
+}
+
+// FileSHA256 returns the hex SHA-256 digest of a file.
+func FileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func cycloneDXDocument(info packit.BuildpackInfo, subject Component, components []Component) map[string]interface{} {
+	entries := make([]map[string]interface{}, 0, len(components))
+	for _, component := range components {
+		entries = append(entries, cycloneDXComponent("library", component))
+	}
+
+	return map[string]interface{}{
+		"bomFormat":   "CycloneDX",
+		"specVersion": "1.4",
+		"version":     1,
+		"metadata": map[string]interface{}{
+			"tools": []map[string]interface{}{
+				{"vendor": "supervise.dev", "name": info.ID, "version": info.Version},
+			},
+			"component": cycloneDXComponent("application", subject),
+		},
+		"components": entries,
+	}
+}
+
+func cycloneDXComponent(kind string, component Component) map[string]interface{} {
+	entry := map[string]interface{}{
+		"type":    kind,
+		"bom-ref": component.PURL,
+		"name":    component.Name,
+		"purl":    component.PURL,
+	}
+
+	if component.Version != "" {
+		entry["version"] = component.Version
+	}
+
+	if component.SHA256 != "" {
+		entry["hashes"] = []map[string]string{
+			{"alg": "SHA-256", "content": component.SHA256},
+		}
+	}
+
+	return entry
+}
+
+func spdxDocument(info packit.BuildpackInfo, subject Component, components []Component) map[string]interface{} {
+	subjectID := "SPDXRef-Package-" + subject.Name
+	packages := []map[string]interface{}{spdxPackage(subjectID, subject)}
+	relationships := []map[string]string{
+		{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": subjectID},
+	}
+
+	digest := sha256.New()
+	digest.Write([]byte(subject.PURL))
+
+	for i, component := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i)
+		packages = append(packages, spdxPackage(id, component))
+		relationships = append(relationships, map[string]string{
+			"spdxElementId": subjectID, "relationshipType": "DEPENDS_ON", "relatedSpdxElement": id,
+		})
+		digest.Write([]byte(component.PURL))
+	}
+
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              subject.Name,
+		"documentNamespace": fmt.Sprintf("https://supervise.dev/spdx/%s-%s", subject.Name, hex.EncodeToString(digest.Sum(nil))),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{fmt.Sprintf("Tool: %s-%s", info.ID, info.Version)},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+func spdxPackage(id string, component Component) map[string]interface{} {
+	version := component.Version
+	if version == "" {
+		version = "NOASSERTION"
+	}
+
+	pkg := map[string]interface{}{
+		"SPDXID":           id,
+		"name":             component.Name,
+		"versionInfo":      version,
+		"downloadLocation": "NOASSERTION",
+		"licenseConcluded": "NOASSERTION",
+		"licenseDeclared":  "NOASSERTION",
+		"copyrightText":    "NOASSERTION",
+		"filesAnalyzed":    false,
+		"externalRefs": []map[string]string{
+			{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": component.PURL},
+		},
+	}
+
+	if component.SHA256 != "" {
+		pkg["checksums"] = []map[string]string{
+			{"algorithm": "SHA256", "checksumValue": component.SHA256},
+		}
+	}
+
+	return pkg
+}
+
+// syftDocument writes the same top-level sections, artifact properties and
+// file entries Syft itself writes for syftSchemaVersion, with the subject as
+// the source.
+func syftDocument(info packit.BuildpackInfo, subject Component, components []Component) map[string]interface{} {
+	subjectID := syftArtifactID(subject)
+	artifacts := []map[string]interface{}{syftArtifact(info, subject)}
+	relationships := []map[string]interface{}{}
+
+	for _, component := range components {
+		artifacts = append(artifacts, syftArtifact(info, component))
+		relationships = append(relationships, map[string]interface{}{
+			"parent": syftArtifactID(component), "child": subjectID, "type": "dependency-of",
+		})
+	}
+
+	files := []map[string]interface{}{}
+	for _, component := range append([]Component{subject}, components...) {
+		if component.Location == "" {
+			continue
+		}
+
+		file := map[string]interface{}{
+			"id":       syftFileID(component.Location),
+			"location": syftCoordinates(component.Location),
+		}
+		if component.SHA256 != "" {
+			file["digests"] = []map[string]string{
+				{"algorithm": "sha256", "value": component.SHA256},
+			}
+		}
+		files = append(files, file)
+	}
+
+	return map[string]interface{}{
+		"artifacts":             artifacts,
+		"artifactRelationships": relationships,
+		"files":                 files,
+		"source": map[string]interface{}{
+			"id":       subjectID,
+			"name":     subject.Name,
+			"version":  subject.Version,
+			"type":     "file",
+			"metadata": map[string]interface{}{"path": subject.Location},
+		},
+		"distro": map[string]interface{}{},
+		"descriptor": map[string]interface{}{
+			"name":          info.ID,
+			"version":       info.Version,
+			"configuration": map[string]interface{}{},
+		},
+		"schema": map[string]interface{}{
+			"version": syftSchemaVersion,
+			"url":     fmt.Sprintf("https://raw.githubusercontent.com/anchore/syft/main/schema/json/schema-%s.json", syftSchemaVersion),
+		},
+	}
+}
+
+func syftArtifact(info packit.BuildpackInfo, component Component) map[string]interface{} {
+	locations := []map[string]interface{}{}
+	if component.Location != "" {
+		location := syftCoordinates(component.Location)
+		location["accessPath"] = component.Location
+		location["annotations"] = map[string]string{"evidence": "primary"}
+		locations = append(locations, location)
+	}
+
+	return map[string]interface{}{
+		"id":        syftArtifactID(component),
+		"name":      component.Name,
+		"version":   component.Version,
+		"type":      component.Type,
+		"foundBy":   info.ID,
+		"locations": locations,
+		"licenses":  []map[string]interface{}{},
+		"language":  syftLanguage(component.Type),
+		"cpes":      []map[string]string{},
+		"purl":      component.PURL,
+	}
+}
+
+func syftCoordinates(path string) map[string]interface{} {
+	return map[string]interface{}{"path": path}
+}
+
+func syftLanguage(packageType string) string {
+	if packageType == "go-module" {
+		return "go"
+	}
+
+	return ""
+}
+
+func syftFileID(location string) string {
+	sum := sha256.Sum256([]byte("file|" + location))
+	return hex.EncodeToString(sum[:8])
+}
+
+func syftArtifactID(component Component) string {
+	sum := sha256.Sum256([]byte(component.PURL + "|" + component.Location))
+	return hex.EncodeToString(sum[:8])
+}
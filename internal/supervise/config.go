@@ -0,0 +1,268 @@
+// Package supervise reads the configuration shared by the Supervise
+// buildpacks from supervise.toml or project.toml.
+package supervise
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	ConfigFile        = "supervise.toml"
+	ProjectDescriptor = "project.toml"
+	DisableEnv        = "BP_SUPERVISE_DISABLE"
+)
+
+// projectTable is where the config lives inside project.toml.
+var projectTable = []string{"_", "metadata", "supervise"}
+
+// freeformTable is passed to ttyd as it is, so the keys under it are not
+// checked.
+var freeformTable = []string{"runtime", "terminal", "client_options"}
+
+// Config is the configuration shared by the Supervise buildpacks: the whole
+// of supervise.toml, or the [_.metadata.supervise] table of project.toml when
+// there is no supervise.toml. Each buildpack reads and validates all of it
+// but only uses its own section; the matching BP_* environment variables
+// override it, and unset values fall back to the buildpack's defaults.
+//
+//	disable = ["caddy"]
+//
+//	[caddy]
+//	xcaddy_version = "v0.4.5"
+//	plugins = ["github.com/ggicci/caddy-jwt"]
+//
+//	[ttyd]
+//	version = "1.7.7"
+//	build_from_source = false
+//	source_toolchain = ["cmake.org", "..."]
+//	source_sha256 = "<digest of the tag's source archive>"
+//
+//	[runtime]
+//	caddy_config_path = "/layers/dev.supervise.caddy/caddy/config/Caddyfile"
+//	agent_command = "pkgx aider"
+//	dev_probe = false
+//	restart = "on_failure:2:5"
+//	writable_paths = ["tmp", "storage"]
+//	writable_mode = "0222"
+//	process_compose = "deploy/process-compose.yaml"
+//
+//	[runtime.process_env.dev]
+//	bindings = ["database"]
+//	env_files = ["/run/secrets/dev.env"]
+//	environment = { LOG_LEVEL = "debug" }
+//
+//	[runtime.terminal]
+//	writable = false
+//	max_clients = 2
+//	ping_interval = 30
+//	client_options = { fontSize = 16, theme = { background = "#1e1e1e" } }
+//
+//	[runtime.sessions]
+//	shell = "bash -l"
+//	logs = "tail -f /tmp/process-compose.log"
+//
+//	[taskfile]
+//	version = "v3.44.0"
+//	build_task = "build"
+//	processes = ["web", "worker"]
+type Config struct {
+	Disable  []string         `toml:"disable"`
+	Caddy    CaddySettings    `toml:"caddy"`
+	TTYD     TTYDSettings     `toml:"ttyd"`
+	Runtime  RuntimeSettings  `toml:"runtime"`
+	Taskfile TaskfileSettings `toml:"taskfile"`
+}
+
+type CaddySettings struct {
+	XCaddyVersion string   `toml:"xcaddy_version"`
+	Plugins       []string `toml:"plugins"`
+}
+
+type TTYDSettings struct {
+	Version         string   `toml:"version"`
+	BuildFromSource bool     `toml:"build_from_source"`
+	SourceToolchain []string `toml:"source_toolchain"`
+	SourceSHA256    string   `toml:"source_sha256"`
+}
+
+// RuntimeSettings is the runtime section. ProcessCompose, Terminal and
+// Sessions replace the app's .supervise/process-compose.yaml,
+// .supervise/ttyd.yaml and .supervise/sessions; an app uses one or the other.
+type RuntimeSettings struct {
+	CaddyConfigPath string   `toml:"caddy_config_path"`
+	AgentCommand    string   `toml:"agent_command"`
+	DevProbe        bool     `toml:"dev_probe"`
+	Restart         string   `toml:"restart"`
+	WritablePaths   []string `toml:"writable_paths"`
+	WritableMode    string   `toml:"writable_mode"`
+	ProcessCompose  string   `toml:"process_compose"`
+
+	ProcessEnv map[string]ProcessEnvSettings `toml:"process_env"`
+	Terminal   *TerminalSettings             `toml:"terminal"`
+	Sessions   map[string]string             `toml:"sessions"`
+}
+
+// ProcessEnvSettings declares the environment of one supervised process:
+// the supervise-env service bindings and env files read at launch, and
+// plain values set at build.
+type ProcessEnvSettings struct {
+	Bindings    []string          `toml:"bindings"`
+	EnvFiles    []string          `toml:"env_files"`
+	Environment map[string]string `toml:"environment"`
+}
+
+// TerminalSettings are the ttyd options of the agent terminal. Writable is
+// a pointer because the terminal is writable unless it is set to false.
+type TerminalSettings struct {
+	Writable      *bool                  `toml:"writable"`
+	MaxClients    int                    `toml:"max_clients"`
+	Once          bool                   `toml:"once"`
+	PingInterval  int                    `toml:"ping_interval"`
+	ClientOptions map[string]interface{} `toml:"client_options"`
+}
+
+type TaskfileSettings struct {
+	Version   string   `toml:"version"`
+	BuildTask string   `toml:"build_task"`
+	Processes []string `toml:"processes"`
+}
+
+// LoadConfig reads the app's Supervise configuration and returns it with the
+// file it came from, or an empty source when the app has none. Unknown keys
+// are an error, so a typo does not silently fall back to a default.
+// BP_SUPERVISE_DISABLE replaces the disable list.
+func LoadConfig(workingDir string) (Config, string, error) {
+	var config Config
+	source := ""
+
+	path := filepath.Join(workingDir, ConfigFile)
+	metadata, err := toml.DecodeFile(path, &config)
+	switch {
+	case err == nil:
+		source = ConfigFile
+		if keys := unknownKeys(metadata, nil); len(keys) > 0 {
+			return Config{}, "", fmt.Errorf("%s: unknown keys %s", ConfigFile, strings.Join(keys, ", "))
+		}
+	case errors.Is(err, os.ErrNotExist):
+		var project struct {
+			Underscore struct {
+				Metadata struct {
+					Supervise Config `toml:"supervise"`
+				} `toml:"metadata"`
+			} `toml:"_"`
+		}
+
+		metadata, err := toml.DecodeFile(filepath.Join(workingDir, ProjectDescriptor), &project)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Config{}, "", fmt.Errorf("failed to parse %s: %w", ProjectDescriptor, err)
+		}
+
+		if metadata.IsDefined(projectTable...) {
+			source = ProjectDescriptor
+			config = project.Underscore.Metadata.Supervise
+			if keys := unknownKeys(metadata, projectTable); len(keys) > 0 {
+				return Config{}, "", fmt.Errorf("%s: unknown keys %s", ProjectDescriptor, strings.Join(keys, ", "))
+			}
+		}
+	default:
+		return Config{}, "", fmt.Errorf("failed to parse %s: %w", ConfigFile, err)
+	}
+
+	OverrideList(DisableEnv, &config.Disable)
+
+	return config, source, nil
+}
+
+// unknownKeys lists the keys under prefix that no field decoded.
+func unknownKeys(metadata toml.MetaData, prefix []string) []string {
+	freeform := append(slices.Clone(prefix), freeformTable...)
+
+	var keys []string
+	for _, key := range metadata.Undecoded() {
+		if hasPrefix(key, prefix) && !hasPrefix(key, freeform) {
+			keys = append(keys, key.String())
+		}
+	}
+
+	return keys
+}
+
+func hasPrefix(key toml.Key, prefix []string) bool {
+	return len(key) > len(prefix) && slices.Equal([]string(key[:len(prefix)]), prefix)
+}
+
+// ComponentDisabled reports whether name is in the disable list of the
+// config (or BP_SUPERVISE_DISABLE), the components to leave out of the
+// image.
+func ComponentDisabled(disable []string, name string) bool {
+	for _, component := range disable {
+		if strings.EqualFold(strings.TrimSpace(component), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OverrideString replaces value with the environment variable env when it
+// is set.
+func OverrideString(env string, value *string) {
+	if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+		*value = v
+	}
+}
+
+// OverrideBool replaces value with the boolean environment variable env when
+// it is set.
+func OverrideBool(env string, value *bool) error {
+	v := strings.TrimSpace(os.Getenv(env))
+	if v == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a boolean", env, v)
+	}
+	*value = parsed
+
+	return nil
+}
+
+// OverrideList replaces value with the comma-separated environment variable
+// env when it is set.
+func OverrideList(env string, value *[]string) {
+	v := strings.TrimSpace(os.Getenv(env))
+	if v == "" {
+		return
+	}
+
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*value = items
+}
+
+// PrintConfig shows the settings a buildpack ended up with after its
+// defaults, the config file and the environment were applied.
+func PrintConfig(source string, settings [][2]string) {
+	if source == "" {
+		source = "defaults and environment"
+	}
+
+	fmt.Printf("Supervise config (%s):\n", source)
+	for _, setting := range settings {
+		fmt.Printf("  %s = %s\n", setting[0], setting[1])
+	}
+}
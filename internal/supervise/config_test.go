@@ -0,0 +1,107 @@
+package supervise
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ConfigFile, `
+disable = ["caddy"]
+
+[runtime]
+process_compose = "deploy/process-compose.yaml"
+
+[runtime.terminal]
+writable = false
+client_options = { fontSize = 16, theme = { background = "#1e1e1e" } }
+
+[runtime.sessions]
+shell = "bash -l"
+`)
+
+	config, source, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source != ConfigFile {
+		t.Errorf("source = %q, want %q", source, ConfigFile)
+	}
+	if !ComponentDisabled(config.Disable, "Caddy") {
+		t.Errorf("caddy is not disabled by %v", config.Disable)
+	}
+
+	terminal := config.Runtime.Terminal
+	if terminal == nil || terminal.Writable == nil || *terminal.Writable {
+		t.Fatalf("terminal = %+v, want writable = false", terminal)
+	}
+	if _, ok := terminal.ClientOptions["theme"].(map[string]interface{}); !ok {
+		t.Errorf("client_options.theme = %#v, want a table", terminal.ClientOptions["theme"])
+	}
+	if config.Runtime.Sessions["shell"] != "bash -l" || config.Runtime.ProcessCompose != "deploy/process-compose.yaml" {
+		t.Errorf("runtime = %+v", config.Runtime)
+	}
+}
+
+func TestLoadConfigProjectDescriptor(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ProjectDescriptor, `
+[_]
+id = "app"
+
+[_.metadata.supervise.ttyd]
+version = "1.7.4"
+`)
+
+	config, source, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source != ProjectDescriptor || config.TTYD.Version != "1.7.4" {
+		t.Errorf("LoadConfig = %+v from %q", config, source)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"top level": "disabled = [\"caddy\"]\n",
+		"section":   "[runtime]\nagent = \"pkgx aider\"\n",
+		"terminal":  "[runtime.terminal]\nmax_client = 2\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, ConfigFile, content)
+
+			if _, _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "unknown keys") {
+				t.Errorf("LoadConfig error = %v, want unknown keys", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDisableEnv(t *testing.T) {
+	t.Setenv(DisableEnv, "ttyd, caddy")
+
+	config, source, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source != "" || !slices.Equal(config.Disable, []string{"ttyd", "caddy"}) {
+		t.Errorf("LoadConfig = %v from %q", config.Disable, source)
+	}
+}
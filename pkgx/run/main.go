@@ -15,6 +15,8 @@ import (
 	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+	"github.com/supervise-dev/buildpack/internal/supervise"
 )
 
 const layerName = "pkgx"
@@ -24,10 +26,28 @@ func main() {
 }
 
 func detect(context packit.DetectContext) (packit.DetectResult, error) {
-	return packit.DetectResult{}, nil
+	return packit.DetectResult{
+		Plan: packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: layerName},
+			},
+		},
+	}, nil
 }
 
 func build(context packit.BuildContext) (packit.BuildResult, error) {
+	// pkgx has no settings of its own, but it builds first in every group,
+	// so a broken Supervise config fails the build before anything is
+	// downloaded.
+	config, source, err := supervise.LoadConfig(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	supervise.PrintConfig(source, [][2]string{
+		{"disable", strings.Join(config.Disable, ", ")},
+	})
+
 	osName, err := uname()
 	if err != nil {
 		return packit.BuildResult{}, fmt.Errorf("failed to determine operating system: %w", err)
@@ -69,12 +89,29 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 		return packit.BuildResult{}, fmt.Errorf("failed to ensure pkgx executable permissions: %w", err)
 	}
 
+	version, err := pkgxVersion(pkgxBinary)
+	if err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to determine pkgx version: %w", err)
+	}
+
+	binarySHA256, err := sbom.FileSHA256(pkgxBinary)
+	if err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to hash pkgx binary: %w", err)
+	}
+
+	layer.SBOM, err = pkgxSBOM(context.BuildpackInfo, version, binarySHA256, archiveURL)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
 	layer.Launch = true
 	layer.Build = true
 	layer.Cache = true
 
 	layer.Metadata = map[string]interface{}{
-		"checksum":          checksum,
+		"archive_checksum":  checksum,
+		"binary_checksum":   binarySHA256,
+		"version":           version,
 		"uri":               archiveURL,
 		"os":                osName,
 		"arch":              arch,
@@ -96,6 +133,22 @@ func uname(args ...string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// pkgxVersion returns the version reported by `pkgx --version`, e.g. "2.7.0"
+// for an output of "pkgx 2.7.0".
+func pkgxVersion(pkgxBinary string) (string, error) {
+	output, err := exec.Command(pkgxBinary, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", errors.New("pkgx --version printed nothing")
+	}
+
+	return fields[len(fields)-1], nil
+}
+
 func fetchArchive(url string) ([]byte, string, error) {
 	resp, err := http.Get(url)
 	if err != nil {
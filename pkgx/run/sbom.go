@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/url"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+)
+
+// pkgxSBOM builds CycloneDX, SPDX and Syft documents for the pkgx binary,
+// recording the version it reports and the digest of the extracted binary.
+func pkgxSBOM(info packit.BuildpackInfo, version, binarySHA256, archiveURL string) (packit.SBOMFormats, error) {
+	subject := sbom.Component{
+		Name:     "pkgx",
+		Version:  version,
+		PURL:     genericPURL("pkgx", version, archiveURL),
+		SHA256:   binarySHA256,
+		Type:     "binary",
+		Location: "bin/pkgx",
+	}
+
+	return sbom.Formats(info, subject, nil)
+}
+
+func genericPURL(name, version, downloadURL string) string {
+	purl := "pkg:generic/" + name
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+
+	return purl + "?download_url=" + url.QueryEscape(downloadURL)
+}
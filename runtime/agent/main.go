@@ -0,0 +1,256 @@
+// Command agent serves the agent terminal: it runs ttyd on a unix socket,
+// attached to a tmux session running SUPERVISE_AGENT_COMMAND, and is started
+// by process-compose as the "agent" process. The tmux server is a separate
+// process-compose process, so the session and the agent in it survive a
+// ttyd restart.
+//
+// Other named sessions (a shell, a log tail, ...) live next to the agent one
+// and are reached through ttyd's URL arguments; see attach, which is the
+// command ttyd runs for each browser connection ("agent attach ...").
+//
+// When SUPERVISE_RECORDING_DIR is set, the output of every session is also
+// recorded as asciicast files by "agent record", which tmux runs through
+// pipe-pane; see recordingConfig.
+//
+// With -read-only the launcher serves the watch terminal instead: ttyd runs
+// without -W on its own socket and attaches read-only to sessions the agent
+// terminal has already started, so observers cannot type into them.
+//
+// Exit codes follow sysexits(3) so process-compose can tell a broken setup
+// from a crash: 64 for an invalid sessions or ttyd config file, 69 when ttyd
+// or pkgx is missing, 70 when the sessions cannot be started, 73 when the
+// socket cannot be prepared, 75 when another ttyd already serves the socket.
+// Otherwise the exit code of ttyd is passed through, and a shutdown requested
+// by a signal exits 0.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultSocketPath  = "/tmp/ttyd/ttyd.sock"
+	defaultSessionName = "agent"
+	defaultTmuxSocket  = "/tmp/tmux-supervise.sock"
+	agentCommandEnv    = "SUPERVISE_AGENT_COMMAND"
+
+	exitUnavailable = 69 // EX_UNAVAILABLE
+	exitSoftware    = 70 // EX_SOFTWARE
+	exitCantCreate  = 73 // EX_CANTCREAT
+	exitTempFail    = 75 // EX_TEMPFAIL
+)
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func main() {
+	var (
+		code int
+		err  error
+	)
+
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "attach":
+		code, err = attach(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "record":
+		code, err = record(os.Args[2:])
+	default:
+		socketPath := flag.String("socket", defaultSocketPath, "unix socket ttyd listens on")
+		session := flag.String("session", defaultSessionName, "tmux session the terminal opens by default")
+		tmuxSocket := flag.String("tmux-socket", defaultTmuxSocket, "socket of the tmux server holding the sessions")
+		sessionsPath := flag.String("sessions", "", "JSON file listing the sessions to start")
+		ttydConfigPath := flag.String("ttyd-config", "", "JSON file with the ttyd options")
+		readOnly := flag.Bool("read-only", false, "serve a read-only view of the sessions")
+		flag.Parse()
+
+		code, err = run(*socketPath, *tmuxSocket, *sessionsPath, *ttydConfigPath, *session, *readOnly)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent: %s\n", err)
+	}
+
+	os.Exit(code)
+}
+
+func run(socketPath, tmuxSocket, sessionsPath, ttydConfigPath, session string, readOnly bool) (int, error) {
+	for _, dependency := range []string{"pkgx", "ttyd"} {
+		if _, err := exec.LookPath(dependency); err != nil {
+			return exitUnavailable, fmt.Errorf("%s not found on PATH: %w", dependency, err)
+		}
+	}
+
+	if err := prepareSocket(socketPath); err != nil {
+		var exit exitError
+		if errors.As(err, &exit) {
+			return exit.code, exit.err
+		}
+		return exitCantCreate, err
+	}
+	defer os.Remove(socketPath)
+
+	sessions, err := loadSessions(sessionsPath)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	config, err := loadTTYDConfig(ttydConfigPath)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	recording, err := loadRecordingConfig()
+	if err != nil {
+		return exitUsage, err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return exitSoftware, fmt.Errorf("failed to locate agent binary: %w", err)
+	}
+
+	attachArgs := []string{"attach", "-tmux-socket", tmuxSocket, "-sessions", sessionsPath, "-session", session}
+	if readOnly {
+		config.Writable = false
+		attachArgs = append(attachArgs, "-read-only")
+	} else {
+		if err := startSessions(tmuxSocket, sessions, session); err != nil {
+			return exitSoftware, err
+		}
+
+		if recording.Dir != "" {
+			if err := startRecording(tmuxSocket, self, recording); err != nil {
+				return exitSoftware, err
+			}
+
+			if recording.UploadURL != "" {
+				uploader, err := newRecordingUploader(recording)
+				if err != nil {
+					return exitUsage, err
+				}
+
+				// Uploading as the recordings grow leaves only the last
+				// interval to copy once ttyd has exited.
+				ctx, cancel := context.WithCancel(context.Background())
+				stopped := make(chan struct{})
+				go func() {
+					uploader.run(ctx, recording.UploadInterval)
+					close(stopped)
+				}()
+
+				defer func() {
+					cancel()
+					<-stopped
+
+					ctx, cancel := context.WithTimeout(context.Background(), shutdownUploadTimeout)
+					defer cancel()
+					if err := uploader.sync(ctx); err != nil {
+						fmt.Fprintf(os.Stderr, "agent: %s\n", err)
+					}
+				}()
+			}
+		}
+	}
+
+	// -a lets the browser pass the session name as a URL argument; ttyd
+	// appends it after "--" so it can only ever be a positional argument.
+	args := append([]string{"+tmux", "--", "ttyd", "-a"}, ttydArgs(config, socketPath)...)
+	args = append(args, self)
+	args = append(args, attachArgs...)
+	args = append(args, "--")
+
+	cmd := exec.Command("pkgx", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return exitUnavailable, fmt.Errorf("failed to start ttyd: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	stopping := false
+	for {
+		select {
+		case sig := <-signals:
+			stopping = true
+			_ = cmd.Process.Signal(sig)
+		case err := <-done:
+			if stopping {
+				return 0, nil
+			}
+
+			var exit *exec.ExitError
+			if errors.As(err, &exit) {
+				return exitCode(exit), fmt.Errorf("ttyd exited: %w", err)
+			}
+			if err != nil {
+				return 1, fmt.Errorf("ttyd failed: %w", err)
+			}
+
+			return 0, nil
+		}
+	}
+}
+
+// prepareSocket creates the socket directory and removes a socket left
+// behind by a ttyd that did not shut down cleanly. A socket that still
+// accepts connections belongs to a running ttyd and is left alone.
+func prepareSocket(socketPath string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	info, err := os.Lstat(socketPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", socketPath, err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", socketPath)
+	}
+
+	if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+		conn.Close()
+		return exitError{code: exitTempFail, err: fmt.Errorf("%s is already served by another process", socketPath)}
+	}
+
+	if err := os.Remove(socketPath); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", socketPath, err)
+	}
+
+	return nil
+}
+
+func exitCode(exit *exec.ExitError) int {
+	if status, ok := exit.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exit.ExitCode()
+}
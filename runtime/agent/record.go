@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	recordingDirEnv            = "SUPERVISE_RECORDING_DIR"
+	recordingMaxSizeEnv        = "SUPERVISE_RECORDING_MAX_SIZE"
+	recordingMaxAgeEnv         = "SUPERVISE_RECORDING_MAX_AGE"
+	recordingRetentionEnv      = "SUPERVISE_RECORDING_RETENTION"
+	recordingMaxTotalSizeEnv   = "SUPERVISE_RECORDING_MAX_TOTAL_SIZE"
+	recordingUploadURLEnv      = "SUPERVISE_RECORDING_UPLOAD_URL"
+	recordingUploadIntervalEnv = "SUPERVISE_RECORDING_UPLOAD_INTERVAL"
+
+	defaultRecordingMaxSize        = 10 << 20
+	defaultRecordingMaxAge         = time.Hour
+	defaultRecordingRetention      = 7 * 24 * time.Hour
+	defaultRecordingMaxTotalSize   = 1 << 30
+	defaultRecordingUploadInterval = 5 * time.Minute
+
+	recordingExtension = ".cast"
+)
+
+// recordingConfig controls the asciicast recordings of the terminal
+// sessions. Recording is off unless SUPERVISE_RECORDING_DIR is set. A file
+// is closed and a new one started once it reaches
+// SUPERVISE_RECORDING_MAX_SIZE bytes or SUPERVISE_RECORDING_MAX_AGE (a Go
+// duration such as "30m"). Each new file prunes the directory: recordings
+// older than SUPERVISE_RECORDING_RETENTION are deleted, then the oldest ones
+// until all of them fit in SUPERVISE_RECORDING_MAX_TOTAL_SIZE bytes. With
+// SUPERVISE_RECORDING_UPLOAD_URL ("https://<endpoint>/<bucket>[/<prefix>]")
+// new and changed recordings are copied to an S3-compatible bucket every
+// SUPERVISE_RECORDING_UPLOAD_INTERVAL and once more at shutdown.
+type recordingConfig struct {
+	Dir            string
+	MaxSize        int64
+	MaxAge         time.Duration
+	Retention      time.Duration
+	MaxTotalSize   int64
+	UploadURL      string
+	UploadInterval time.Duration
+}
+
+func loadRecordingConfig() (recordingConfig, error) {
+	config := recordingConfig{
+		Dir:            strings.TrimSpace(os.Getenv(recordingDirEnv)),
+		MaxSize:        defaultRecordingMaxSize,
+		MaxAge:         defaultRecordingMaxAge,
+		Retention:      defaultRecordingRetention,
+		MaxTotalSize:   defaultRecordingMaxTotalSize,
+		UploadURL:      strings.TrimSpace(os.Getenv(recordingUploadURLEnv)),
+		UploadInterval: defaultRecordingUploadInterval,
+	}
+
+	for _, setting := range []struct {
+		env   string
+		value *int64
+	}{
+		{recordingMaxSizeEnv, &config.MaxSize},
+		{recordingMaxTotalSizeEnv, &config.MaxTotalSize},
+	} {
+		if value := strings.TrimSpace(os.Getenv(setting.env)); value != "" {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return recordingConfig{}, fmt.Errorf("%s: %q is not a positive number of bytes", setting.env, value)
+			}
+			*setting.value = size
+		}
+	}
+
+	for _, setting := range []struct {
+		env   string
+		value *time.Duration
+	}{
+		{recordingMaxAgeEnv, &config.MaxAge},
+		{recordingRetentionEnv, &config.Retention},
+		{recordingUploadIntervalEnv, &config.UploadInterval},
+	} {
+		if value := strings.TrimSpace(os.Getenv(setting.env)); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return recordingConfig{}, fmt.Errorf("%s: %q is not a positive duration", setting.env, value)
+			}
+			*setting.value = duration
+		}
+	}
+
+	if config.Dir != "" && !filepath.IsAbs(config.Dir) {
+		return recordingConfig{}, fmt.Errorf("%s: %q is not an absolute path", recordingDirEnv, config.Dir)
+	}
+
+	return config, nil
+}
+
+// startRecording pipes the output of every session on the tmux server into
+// "agent record". A session-created hook covers the sessions opened later
+// from the browser; pipe-pane -o leaves panes that are already recorded (for
+// example after an agent restart) alone.
+func startRecording(tmuxSocket, self string, config recordingConfig) error {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	if err := pruneRecordings(config.Dir, config.Retention, config.MaxTotalSize, time.Now()); err != nil {
+		return err
+	}
+
+	recorder := fmt.Sprintf("exec %s record -dir %s -max-size %d -max-age %s -retention %s -max-total-size %d -session '#{session_name}' -width '#{pane_width}' -height '#{pane_height}'",
+		shellQuote(self), shellQuote(config.Dir), config.MaxSize, config.MaxAge, config.Retention, config.MaxTotalSize)
+
+	tmux := func(args ...string) *exec.Cmd {
+		cmd := exec.Command("pkgx", append([]string{"+tmux", "--", "tmux", "-S", tmuxSocket}, args...)...)
+		cmd.Stderr = os.Stderr
+		return cmd
+	}
+
+	if err := tmux("set-hook", "-g", "session-created", "pipe-pane -o "+tmuxQuote(recorder)).Run(); err != nil {
+		return fmt.Errorf("failed to install recording hook: %w", err)
+	}
+
+	output, err := tmux("list-sessions", "-F", "#{session_name}").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list tmux sessions: %w", err)
+	}
+
+	for _, name := range strings.Fields(string(output)) {
+		if err := tmux("pipe-pane", "-o", "-t", "="+name, recorder).Run(); err != nil {
+			return fmt.Errorf("failed to record tmux session %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// record is what tmux's pipe-pane runs: it reads the pane output from stdin
+// and writes it as asciicast v2 files, which asciinema can play back.
+func record(args []string) (int, error) {
+	flags := flag.NewFlagSet("record", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory the recordings are written to")
+	session := flags.String("session", "", "name of the recorded tmux session")
+	width := flags.Int("width", 80, "terminal width")
+	height := flags.Int("height", 24, "terminal height")
+	maxSize := flags.Int64("max-size", defaultRecordingMaxSize, "size in bytes after which a new file is started")
+	maxAge := flags.Duration("max-age", defaultRecordingMaxAge, "age after which a new file is started")
+	retention := flags.Duration("retention", defaultRecordingRetention, "age after which recordings are deleted")
+	maxTotalSize := flags.Int64("max-total-size", defaultRecordingMaxTotalSize, "size in bytes all recordings are pruned to")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage, err
+	}
+
+	if *dir == "" || !sessionName.MatchString(*session) {
+		return exitUsage, fmt.Errorf("record needs -dir and a valid -session")
+	}
+
+	r := &recorder{
+		dir:          *dir,
+		session:      *session,
+		width:        *width,
+		height:       *height,
+		maxSize:      *maxSize,
+		maxAge:       *maxAge,
+		retention:    *retention,
+		maxTotalSize: *maxTotalSize,
+	}
+	defer r.close()
+
+	return copyRecording(os.Stdin, r)
+}
+
+// copyRecording writes everything read from input to the recorder.
+func copyRecording(input io.Reader, r *recorder) (int, error) {
+	buffer := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := input.Read(buffer)
+		if n > 0 {
+			pending = append(pending, buffer[:n]...)
+
+			// Hold back a multi-byte character split across reads so it is
+			// not written as two invalid halves.
+			complete := len(pending)
+			for i := len(pending) - 1; i >= 0 && i >= len(pending)-utf8.UTFMax; i-- {
+				if utf8.RuneStart(pending[i]) {
+					if !utf8.FullRune(pending[i:]) {
+						complete = i
+					}
+					break
+				}
+			}
+
+			if complete > 0 {
+				if err := r.write(pending[:complete]); err != nil {
+					return exitCantCreate, err
+				}
+				pending = append(pending[:0], pending[complete:]...)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return exitSoftware, fmt.Errorf("failed to read session output: %w", err)
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := r.write(pending); err != nil {
+			return exitCantCreate, err
+		}
+	}
+
+	return 0, nil
+}
+
+type recorder struct {
+	dir          string
+	session      string
+	width        int
+	height       int
+	maxSize      int64
+	maxAge       time.Duration
+	retention    time.Duration
+	maxTotalSize int64
+
+	file    *os.File
+	started time.Time
+	size    int64
+}
+
+func (r *recorder) write(data []byte) error {
+	now := time.Now()
+	if r.file == nil || r.size >= r.maxSize || now.Sub(r.started) >= r.maxAge {
+		if err := r.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	event, err := json.Marshal([]interface{}{now.Sub(r.started).Seconds(), "o", string(data)})
+	if err != nil {
+		return fmt.Errorf("failed to encode recording event: %w", err)
+	}
+
+	return r.writeLine(event)
+}
+
+func (r *recorder) rotate(now time.Time) error {
+	r.close()
+
+	path := filepath.Join(r.dir, fmt.Sprintf("%s-%s%s", r.session, now.UTC().Format("20060102T150405.000000000Z"), recordingExtension))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r.file = file
+	r.started = now
+	r.size = 0
+
+	// Other sessions' recorders prune the same directory, so a failure here
+	// is reported but does not stop this recording.
+	if err := pruneRecordings(r.dir, r.retention, r.maxTotalSize, now); err != nil {
+		fmt.Fprintf(os.Stderr, "agent: %s\n", err)
+	}
+
+	header, err := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     r.width,
+		"height":    r.height,
+		"timestamp": now.Unix(),
+		"title":     r.session,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode recording header: %w", err)
+	}
+
+	return r.writeLine(header)
+}
+
+func (r *recorder) writeLine(line []byte) error {
+	n, err := r.file.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	return nil
+}
+
+func (r *recorder) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// pruneRecordings deletes the recordings in dir last written more than
+// retention ago, then the oldest remaining ones until their total size is at
+// most maxTotalSize. The newest recording, usually the one being written, is
+// always kept.
+func pruneRecordings(dir string, retention time.Duration, maxTotalSize int64, now time.Time) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+recordingExtension))
+	if err != nil {
+		return fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	type recording struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var recordings []recording
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to stat recording: %w", err)
+		}
+
+		recordings = append(recordings, recording{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].modTime.Before(recordings[j].modTime) })
+
+	for i, rec := range recordings {
+		if i == len(recordings)-1 || (now.Sub(rec.modTime) <= retention && total <= maxTotalSize) {
+			break
+		}
+
+		if err := os.Remove(rec.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete recording: %w", err)
+		}
+		total -= rec.size
+	}
+
+	return nil
+}
+
+// shellQuote quotes arg for the shell tmux runs pipe-pane commands with.
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// tmuxQuote quotes a command as a single argument in tmux's own command
+// syntax, used for the hook.
+func tmuxQuote(command string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(command) + `"`
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func newTestRecorder(dir string) *recorder {
+	return &recorder{
+		dir:          dir,
+		session:      "agent",
+		width:        120,
+		height:       40,
+		maxSize:      defaultRecordingMaxSize,
+		maxAge:       defaultRecordingMaxAge,
+		retention:    defaultRecordingRetention,
+		maxTotalSize: defaultRecordingMaxTotalSize,
+	}
+}
+
+// readCast returns the header and the output events of an asciicast file.
+func readCast(t *testing.T, path string) (map[string]interface{}, []string) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatalf("%s is empty", path)
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("invalid header: %v", err)
+	}
+
+	var output []string
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		if len(event) != 3 || event[1] != "o" {
+			t.Fatalf("unexpected event %q", scanner.Text())
+		}
+		if _, ok := event[0].(float64); !ok {
+			t.Fatalf("event time %v is not a number", event[0])
+		}
+		output = append(output, event[2].(string))
+	}
+
+	return header, output
+}
+
+func recordings(t *testing.T, dir string) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+recordingExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+
+	return paths
+}
+
+func TestCopyRecordingWritesAsciicast(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestRecorder(dir)
+
+	// One byte per read splits "é" and "→" across reads.
+	input := iotest.OneByteReader(strings.NewReader("héllo → world\r\n"))
+	if code, err := copyRecording(input, r); err != nil || code != 0 {
+		t.Fatalf("copyRecording = %d, %v", code, err)
+	}
+	r.close()
+
+	paths := recordings(t, dir)
+	if len(paths) != 1 {
+		t.Fatalf("got %d recordings, want 1", len(paths))
+	}
+	if name := filepath.Base(paths[0]); !strings.HasPrefix(name, "agent-") {
+		t.Errorf("recording %s is not named after the session", name)
+	}
+
+	header, output := readCast(t, paths[0])
+	if header["version"] != float64(2) || header["width"] != float64(120) || header["height"] != float64(40) || header["title"] != "agent" {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	if got := strings.Join(output, ""); got != "héllo → world\r\n" {
+		t.Errorf("output = %q", got)
+	}
+	for _, event := range output {
+		if strings.ContainsRune(event, '�') {
+			t.Errorf("event %q has a split character", event)
+		}
+	}
+}
+
+func TestCopyRecordingRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestRecorder(dir)
+	r.maxSize = 100
+
+	for range 5 {
+		if _, err := copyRecording(strings.NewReader(strings.Repeat("x", 80)), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.close()
+
+	paths := recordings(t, dir)
+	if len(paths) != 5 {
+		t.Fatalf("got %d recordings, want one per write past the size limit", len(paths))
+	}
+
+	for _, path := range paths {
+		if _, output := readCast(t, path); len(output) != 1 {
+			t.Errorf("%s has %d events, want 1", path, len(output))
+		}
+	}
+}
+
+func TestPruneRecordings(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		ages         []time.Duration // one 100 byte recording each
+		retention    time.Duration
+		maxTotalSize int64
+		want         []int // indexes into ages that are kept
+	}{
+		{
+			name:         "keeps recent recordings within the size limit",
+			ages:         []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour},
+			retention:    24 * time.Hour,
+			maxTotalSize: 1000,
+			want:         []int{0, 1, 2},
+		},
+		{
+			name:         "deletes recordings past the retention",
+			ages:         []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour},
+			retention:    24 * time.Hour,
+			maxTotalSize: 1000,
+			want:         []int{2},
+		},
+		{
+			name:         "deletes the oldest recordings over the size limit",
+			ages:         []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour, 0},
+			retention:    24 * time.Hour,
+			maxTotalSize: 250,
+			want:         []int{2, 3},
+		},
+		{
+			name:         "keeps the newest recording",
+			ages:         []time.Duration{48 * time.Hour, 24*time.Hour + time.Minute},
+			retention:    time.Hour,
+			maxTotalSize: 10,
+			want:         []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var paths []string
+			for i, age := range tt.ages {
+				path := filepath.Join(dir, "agent-"+string(rune('a'+i))+recordingExtension)
+				if err := os.WriteFile(path, []byte(strings.Repeat("x", 100)), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+
+			if err := pruneRecordings(dir, tt.retention, tt.maxTotalSize, now); err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, i := range tt.want {
+				want = append(want, paths[i])
+			}
+
+			if got := recordings(t, dir); !slices.Equal(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+		})
+	}
+}
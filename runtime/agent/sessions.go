@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"syscall"
+)
+
+// exitUsage is returned by attach for a session name the browser should not
+// have been able to request.
+const exitUsage = 64 // EX_USAGE
+
+var sessionName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// session is one entry of the sessions file the runtime buildpack writes. An
+// empty Command starts the agent for the default session and a login shell
+// for any other.
+type session struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+func loadSessions(path string) ([]session, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions file: %w", err)
+	}
+
+	var sessions []session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse sessions file %s: %w", path, err)
+	}
+
+	for _, s := range sessions {
+		if !sessionName.MatchString(s.Name) {
+			return nil, fmt.Errorf("sessions file %s: invalid session name %q", path, s.Name)
+		}
+	}
+
+	return sessions, nil
+}
+
+// sessionCommand returns the command a new session called name runs.
+func sessionCommand(sessions []session, name, defaultSession string) string {
+	for _, s := range sessions {
+		if s.Name == name && s.Command != "" {
+			return s.Command
+		}
+	}
+
+	if name == defaultSession {
+		return agentCommand()
+	}
+
+	return ""
+}
+
+// agentCommand is set by the runtime buildpack in the launch environment,
+// which also owns the pinned default. Without it the session runs tmux's
+// default shell.
+func agentCommand() string {
+	return os.Getenv(agentCommandEnv)
+}
+
+// startSessions creates the configured sessions that do not exist yet, so
+// they are already running before anyone opens them in a browser.
+func startSessions(tmuxSocket string, sessions []session, defaultSession string) error {
+	names := []string{defaultSession}
+	for _, s := range sessions {
+		if s.Name != defaultSession {
+			names = append(names, s.Name)
+		}
+	}
+
+	for _, name := range names {
+		if exec.Command("pkgx", "+tmux", "--", "tmux", "-S", tmuxSocket, "has-session", "-t", "="+name).Run() == nil {
+			continue
+		}
+
+		args := []string{"+tmux", "--", "tmux", "-S", tmuxSocket, "new-session", "-d", "-s", name}
+		if command := sessionCommand(sessions, name, defaultSession); command != "" {
+			args = append(args, command)
+		}
+
+		cmd := exec.Command("pkgx", args...)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to start tmux session %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// attach is what ttyd runs for every browser connection. ttyd appends the
+// URL's arg query parameters after "--", so /terminal/<name> (which Caddy
+// redirects to ?arg=<name>) attaches to session <name>, creating it if
+// needed. Without an argument the default session is used. With -read-only
+// it attaches to an existing session with tmux's read-only flag instead.
+func attach(args []string) (int, error) {
+	flags := flag.NewFlagSet("attach", flag.ContinueOnError)
+	tmuxSocket := flags.String("tmux-socket", defaultTmuxSocket, "socket of the tmux server holding the sessions")
+	sessionsPath := flags.String("sessions", "", "JSON file listing the configured sessions")
+	defaultSession := flags.String("session", defaultSessionName, "session to attach to when none is requested")
+	readOnly := flags.Bool("read-only", false, "attach without accepting input")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage, err
+	}
+
+	name := *defaultSession
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		name = flags.Arg(0)
+	default:
+		return exitUsage, fmt.Errorf("expected at most one session name, got %d", flags.NArg())
+	}
+
+	if !sessionName.MatchString(name) {
+		return exitUsage, fmt.Errorf("invalid session name %q", name)
+	}
+
+	sessions, err := loadSessions(*sessionsPath)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	pkgx, err := exec.LookPath("pkgx")
+	if err != nil {
+		return exitUnavailable, fmt.Errorf("pkgx not found on PATH: %w", err)
+	}
+
+	argv := []string{"pkgx", "+tmux", "--", "tmux", "-2", "-u", "-S", *tmuxSocket}
+	if *readOnly {
+		argv = append(argv, "attach-session", "-r", "-t", "="+name)
+	} else {
+		argv = append(argv, "new-session", "-A", "-s", name)
+		if command := sessionCommand(sessions, name, *defaultSession); command != "" {
+			argv = append(argv, command)
+		}
+	}
+
+	// Replace this process so ttyd's pty drives tmux directly.
+	if err := syscall.Exec(pkgx, argv, os.Environ()); err != nil {
+		return exitUnavailable, fmt.Errorf("failed to exec tmux: %w", err)
+	}
+
+	return 0, nil
+}
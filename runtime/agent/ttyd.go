@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const (
+	credentialEnv = "SUPERVISE_TTYD_CREDENTIAL"
+	jwtAuthEnv    = "ENABLE_JWT_AUTH"
+)
+
+// ttydConfig mirrors the ttyd.json the runtime buildpack writes from the
+// app's runtime.terminal settings or .supervise/ttyd.yaml.
+type ttydConfig struct {
+	Writable      bool              `json:"writable"`
+	MaxClients    int               `json:"max_clients,omitempty"`
+	Once          bool              `json:"once,omitempty"`
+	PingInterval  int               `json:"ping_interval,omitempty"`
+	ClientOptions map[string]string `json:"client_options,omitempty"`
+}
+
+func loadTTYDConfig(path string) (ttydConfig, error) {
+	config := ttydConfig{Writable: true}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return ttydConfig{}, fmt.Errorf("failed to read ttyd config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ttydConfig{}, fmt.Errorf("failed to parse ttyd config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// ttydArgs builds the ttyd flags for the configured options. Caddy normally
+// authenticates requests and vouches for them with the X-WEBAUTH-USER header;
+// when JWT auth is off and SUPERVISE_TTYD_CREDENTIAL ("user:password") is
+// set, ttyd asks for basic auth itself instead. The credential is read at
+// launch so it never ends up in an image layer.
+func ttydArgs(config ttydConfig, socketPath string) []string {
+	args := []string{"-i", socketPath}
+
+	if config.Writable {
+		args = append(args, "-W")
+	}
+	if config.MaxClients > 0 {
+		args = append(args, "-m", strconv.Itoa(config.MaxClients))
+	}
+	if config.Once {
+		args = append(args, "-o")
+	}
+	if config.PingInterval > 0 {
+		args = append(args, "-P", strconv.Itoa(config.PingInterval))
+	}
+
+	keys := make([]string, 0, len(config.ClientOptions))
+	for key := range config.ClientOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		args = append(args, "-t", key+"="+config.ClientOptions[key])
+	}
+
+	if credential := os.Getenv(credentialEnv); credential != "" && os.Getenv(jwtAuthEnv) != "true" {
+		args = append(args, "-c", credential)
+	} else {
+		args = append(args, "-H", "X-WEBAUTH-USER")
+	}
+
+	return args
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	uploadTimeout   = time.Minute
+
+	// shutdownUploadTimeout bounds the last upload at shutdown, which only
+	// has the changes since the previous interval to copy. The runtime gives
+	// the agent process a longer process-compose shutdown timeout.
+	shutdownUploadTimeout = 20 * time.Second
+)
+
+// recordingUploader copies recordings to the bucket named by the upload URL,
+// using path-style requests so MinIO and other S3-compatible servers work
+// without DNS set up per bucket. Credentials come from the usual
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION
+// variables. A recording is uploaded again whenever it has changed, which
+// overwrites the object, so one still being written is replaced as it grows.
+type recordingUploader struct {
+	base        *url.URL
+	credentials s3Credentials
+	dir         string
+	client      *http.Client
+	uploaded    map[string]uploadedRecording // by file name
+}
+
+type uploadedRecording struct {
+	size    int64
+	modTime time.Time
+}
+
+// matches reports whether info describes the recording as uploaded. The
+// modification times are compared with Equal, as == also compares their
+// location and monotonic reading.
+func (r uploadedRecording) matches(info os.FileInfo) bool {
+	return r.size == info.Size() && r.modTime.Equal(info.ModTime())
+}
+
+func newRecordingUploader(config recordingConfig) (*recordingUploader, error) {
+	base, err := url.Parse(config.UploadURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("%s: %q is not an http(s) URL", recordingUploadURLEnv, config.UploadURL)
+	}
+
+	credentials := s3Credentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       os.Getenv("AWS_REGION"),
+	}
+	if credentials.AccessKey == "" || credentials.SecretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to upload recordings")
+	}
+	if credentials.Region == "" {
+		credentials.Region = defaultS3Region
+	}
+
+	return &recordingUploader{
+		base:        base,
+		credentials: credentials,
+		dir:         config.Dir,
+		client:      http.DefaultClient,
+		uploaded:    map[string]uploadedRecording{},
+	}, nil
+}
+
+// run syncs the recordings every interval until ctx is done.
+func (u *recordingUploader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+			if err := u.sync(syncCtx); err != nil {
+				fmt.Fprintf(os.Stderr, "agent: %s\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// sync uploads the recordings that are new or changed since their last
+// upload. A failed upload is retried on the next sync.
+func (u *recordingUploader) sync(ctx context.Context) error {
+	recordings, err := filepath.Glob(filepath.Join(u.dir, "*"+recordingExtension))
+	if err != nil {
+		return fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	for _, recording := range recordings {
+		name := filepath.Base(recording)
+
+		info, err := os.Stat(recording)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // pruned since it was listed
+			}
+			return fmt.Errorf("failed to stat recording: %w", err)
+		}
+
+		if uploaded, ok := u.uploaded[name]; ok && uploaded.matches(info) {
+			continue
+		}
+
+		if err := u.upload(ctx, recording); err != nil {
+			return err
+		}
+		u.uploaded[name] = uploadedRecording{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	return nil
+}
+
+func (u *recordingUploader) upload(ctx context.Context, recording string) error {
+	data, err := os.ReadFile(recording)
+	if err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	target := *u.base
+	target.Path = path.Join("/", u.base.Path, filepath.Base(recording))
+	target.RawPath = ""
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-asciicast")
+	signS3Request(request, data, u.credentials, time.Now())
+
+	response, err := u.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", filepath.Base(recording), err)
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to upload %s: %s: %s", filepath.Base(recording), response.Status, strings.TrimSpace(string(body)))
+	}
+
+	fmt.Fprintf(os.Stderr, "agent: uploaded recording %s\n", target.Redacted())
+
+	return nil
+}
+
+type s3Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+}
+
+// signS3Request adds an AWS Signature Version 4 Authorization header to
+// request, signing the host, every header already set and the payload hash.
+func signS3Request(request *http.Request, payload []byte, credentials s3Credentials, now time.Time) {
+	const algorithm = "AWS4-HMAC-SHA256"
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(payload)
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3EscapePath(request.URL.Path),
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, credentials.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretKey)
+	for _, part := range []string{date, credentials.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, credentials.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath percent-encodes everything but unreserved characters and
+// slashes, which is stricter than net/url and what the signature expects.
+func s3EscapePath(p string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(p) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+
+	return escaped.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,203 @@
+# Sourced by process-compose in front of every generated process command:
+#
+#   set -- <process> [--claimed a,b] [--binding name]... [--file path]... [--dotenv path]... && . supervise-env.sh
+#
+# It exports the environment declared for the process at launch, so secrets
+# are read from the container's mounts and never end up in an image layer:
+#
+#   1. env files (--file), in order; a missing file fails the process.
+#   2. service bindings of type supervise-env under $SERVICE_BINDING_ROOT.
+#      Every key file (other than type and provider) becomes a variable. A
+#      binding listed in --claimed is only exported to the processes that
+#      name it with --binding; any other supervise-env binding is exported to
+#      every process.
+#
+#   3. the app's .env files (--dotenv), which only set variables that are
+#      still unset, so the first file given wins. A file deleted since the
+#      build is skipped.
+#
+# Steps 1 and 2 override the environment process-compose starts the process
+# with, bindings winning over env files; step 3 fills in around it. .env files
+# are parsed rather than sourced, with the grammar of the runtime's
+# parseDotenv, which checks them at build time.
+
+_se_process="$1"
+shift
+
+_se_claimed=""
+_se_bindings=""
+_se_files=""
+_se_dotenvs=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--claimed) _se_claimed="$2"; shift 2 ;;
+	--binding) _se_bindings="$_se_bindings $2"; shift 2 ;;
+	--file) _se_files="$_se_files
+$2"; shift 2 ;;
+	--dotenv) _se_dotenvs="$_se_dotenvs
+$2"; shift 2 ;;
+	*) echo "supervise-env: $_se_process: unknown argument $1" >&2; return 64 ;;
+	esac
+done
+
+_se_status=0
+
+# Env files are KEY=value lines, sourced with allexport so that quoting and
+# comments follow the shell's rules.
+_se_ifs="$IFS"
+IFS='
+'
+for _se_file in $_se_files; do
+	if [ ! -f "$_se_file" ]; then
+		echo "supervise-env: $_se_process: env file $_se_file does not exist" >&2
+		_se_status=66
+		break
+	fi
+
+	set -a
+	. "$_se_file" || _se_status=65
+	set +a
+done
+IFS="$_se_ifs"
+
+if [ "$_se_status" -eq 0 ] && [ -n "${SERVICE_BINDING_ROOT:-}" ] && [ -d "$SERVICE_BINDING_ROOT" ]; then
+	for _se_dir in "$SERVICE_BINDING_ROOT"/*/; do
+		_se_dir="${_se_dir%/}"
+		_se_name="${_se_dir##*/}"
+
+		[ "$(cat "$_se_dir/type" 2>/dev/null)" = "supervise-env" ] || continue
+
+		case ",$_se_claimed," in
+		*",$_se_name,"*)
+			case " $_se_bindings " in
+			*" $_se_name "*) ;;
+			*) continue ;;
+			esac
+			;;
+		esac
+
+		for _se_entry in "$_se_dir"/*; do
+			[ -f "$_se_entry" ] || continue
+			_se_key="${_se_entry##*/}"
+			case "$_se_key" in
+			type | provider) continue ;;
+			[0-9]* | *[!A-Za-z0-9_]*)
+				echo "supervise-env: $_se_process: skipping key $_se_key of binding $_se_name: not a variable name" >&2
+				continue
+				;;
+			esac
+
+			export "$_se_key=$(cat "$_se_entry")"
+		done
+	done
+
+	for _se_name in $_se_bindings; do
+		[ -d "$SERVICE_BINDING_ROOT/$_se_name" ] ||
+			echo "supervise-env: $_se_process: binding $_se_name is not mounted" >&2
+	done
+elif [ "$_se_status" -eq 0 ] && [ -n "$_se_bindings" ]; then
+	echo "supervise-env: $_se_process: no SERVICE_BINDING_ROOT, bindings$_se_bindings are not mounted" >&2
+fi
+
+# _se_dotenv_error <file> <line> <message> fails the process.
+_se_dotenv_error() {
+	echo "supervise-env: $_se_process: $1:$2: $3" >&2
+	_se_status=65
+}
+
+# _se_dotenv <file> exports the KEY=value lines of a .env file whose
+# variables are unset.
+_se_dotenv() {
+	_se_number=0
+	while IFS= read -r _se_line || [ -n "$_se_line" ]; do
+		_se_number=$((_se_number + 1))
+		_se_line="${_se_line#"${_se_line%%[![:space:]]*}"}"
+		_se_line="${_se_line%"${_se_line##*[![:space:]]}"}"
+		case "$_se_line" in
+		"" | "#"*) continue ;;
+		export[[:blank:]]*)
+			_se_line="${_se_line#export}"
+			_se_line="${_se_line#"${_se_line%%[![:space:]]*}"}"
+			;;
+		esac
+
+		case "$_se_line" in
+		*=*) ;;
+		*) _se_dotenv_error "$1" "$_se_number" "expected KEY=value"; return ;;
+		esac
+
+		_se_key="${_se_line%%=*}"
+		_se_key="${_se_key%"${_se_key##*[![:blank:]]}"}"
+		case "$_se_key" in
+		"" | [0-9]* | *[!A-Za-z0-9_]*) _se_dotenv_error "$1" "$_se_number" "invalid variable name \"$_se_key\""; return ;;
+		esac
+
+		_se_rest="${_se_line#*=}"
+		_se_rest="${_se_rest#"${_se_rest%%[![:blank:]]*}"}"
+		case "$_se_rest" in
+		"'"*)
+			_se_rest="${_se_rest#?}"
+			case "$_se_rest" in
+			*"'"*) ;;
+			*) _se_dotenv_error "$1" "$_se_number" "unterminated single-quoted value for $_se_key"; return ;;
+			esac
+			_se_value="${_se_rest%%"'"*}"
+			_se_rest="${_se_rest#*"'"}"
+			;;
+		'"'*)
+			_se_rest="${_se_rest#?}"
+			_se_value=""
+			while :; do
+				case "$_se_rest" in
+				"") _se_dotenv_error "$1" "$_se_number" "unterminated double-quoted value for $_se_key"; return ;;
+				'"'*) _se_rest="${_se_rest#?}"; break ;;
+				"\\"?*)
+					_se_rest="${_se_rest#?}"
+					_se_char="${_se_rest%"${_se_rest#?}"}"
+					[ "$_se_char" = n ] && _se_char='
+'
+					_se_value="$_se_value$_se_char"
+					_se_rest="${_se_rest#?}"
+					;;
+				"\\") _se_rest="" ;;
+				*)
+					_se_value="$_se_value${_se_rest%"${_se_rest#?}"}"
+					_se_rest="${_se_rest#?}"
+					;;
+				esac
+			done
+			;;
+		*)
+			_se_value="${_se_rest%%[[:blank:]]#*}"
+			_se_value="${_se_value%"${_se_value##*[![:blank:]]}"}"
+			_se_rest=""
+			;;
+		esac
+
+		_se_rest="${_se_rest#"${_se_rest%%[![:blank:]]*}"}"
+		case "$_se_rest" in
+		"" | "#"*) ;;
+		*) _se_dotenv_error "$1" "$_se_number" "unexpected \"$_se_rest\" after the quoted value for $_se_key"; return ;;
+		esac
+
+		eval "_se_set=\${$_se_key+x}"
+		[ -n "$_se_set" ] || export "$_se_key=$_se_value"
+	done <"$1"
+}
+
+if [ "$_se_status" -eq 0 ]; then
+	IFS='
+'
+	for _se_file in $_se_dotenvs; do
+		IFS="$_se_ifs"
+		[ -f "$_se_file" ] || continue
+		_se_dotenv "$_se_file"
+		[ "$_se_status" -eq 0 ] || break
+	done
+	IFS="$_se_ifs"
+fi
+
+unset -f _se_dotenv _se_dotenv_error
+unset _se_process _se_claimed _se_bindings _se_files _se_dotenvs _se_ifs _se_file _se_dir _se_name _se_entry _se_key \
+	_se_number _se_line _se_rest _se_value _se_char _se_set
+eval "unset _se_status; return $_se_status"
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	caddyRoutesEnv         = "SUPERVISE_CADDY_ROUTES"
+	postStartProcessType   = "post-start"
+	devcontainerPortPrefix = "/ports/"
+
+	// launchEnvDelimiter separates a prepended or appended value from the
+	// container's value, as in PATH.
+	launchEnvDelimiter = ":"
+)
+
+// devcontainerPaths are where VS Code looks for a dev container definition.
+var devcontainerPaths = []string{
+	filepath.Join(".devcontainer", "devcontainer.json"),
+	".devcontainer.json",
+}
+
+// devcontainerFeatures maps dev container features (by their id without
+// registry and version, e.g. "ghcr.io/devcontainers/features/node:1" is
+// "node") onto the pkgx packages that provide the same tools. A feature's
+// version option becomes the package constraint.
+var devcontainerFeatures = map[string][]string{
+	"aws-cli":               {"aws.amazon.com/cli"},
+	"bun":                   {"bun.sh"},
+	"deno":                  {"deno.land"},
+	"dotnet":                {"dotnet.microsoft.com"},
+	"git":                   {"git-scm.org"},
+	"git-lfs":               {"git-lfs.com"},
+	"github-cli":            {"cli.github.com"},
+	"go":                    {"go.dev"},
+	"java":                  {"openjdk.org"},
+	"kubectl-helm-minikube": {"kubernetes.io/kubectl", "helm.sh"},
+	"node":                  {"nodejs.org"},
+	"php":                   {"php.net"},
+	"python":                {"python.org"},
+	"ruby":                  {"ruby-lang.org"},
+	"rust":                  {"rust-lang.org", "rust-lang.org/cargo"},
+	"terraform":             {"terraform.io"},
+}
+
+// devcontainerNoopFeatures set up things the buildpack image already has.
+var devcontainerNoopFeatures = []string{"common-utils"}
+
+type devcontainerConfig struct {
+	ContainerEnv      map[string]string      `json:"containerEnv"`
+	ForwardPorts      []interface{}          `json:"forwardPorts"`
+	PostStartCommand  interface{}            `json:"postStartCommand"`
+	PostCreateCommand interface{}            `json:"postCreateCommand"`
+	Features          map[string]interface{} `json:"features"`
+}
+
+// devcontainer is what the runtime takes from the app's devcontainer.json:
+// launch environment, Caddy routes for forwarded ports, a one-shot
+// post-start process and pkgx packages for the features.
+type devcontainer struct {
+	Path             string
+	Env              []launchVariable
+	Ports            []forwardedPort
+	PostStartCommand string
+	Packages         []string
+	Skipped          []string          // features and settings that have no equivalent
+	SkippedEnv       map[string]string // containerEnv entries left out, with the reason
+}
+
+// Modes of a launchVariable, after the packit.Environment methods they map
+// onto.
+const (
+	launchEnvDefault = "default"
+	launchEnvPrepend = "prepend"
+	launchEnvAppend  = "append"
+)
+
+// launchVariable is a containerEnv entry as the launch environment of the
+// layer. The launcher does not expand variables, so a reference to the
+// container's own value becomes a prepend or append to it.
+type launchVariable struct {
+	Name  string
+	Value string
+	Mode  string
+}
+
+type forwardedPort struct {
+	Label string // path segment under /ports/
+	Host  string
+	Port  int
+}
+
+// readDevcontainer parses the app's devcontainer.json, which may contain
+// comments and trailing commas like any VS Code settings file. It returns
+// an empty Path when the app has none.
+func readDevcontainer(workingDir string) (devcontainer, error) {
+	var path string
+	var data []byte
+	for _, candidate := range devcontainerPaths {
+		content, err := os.ReadFile(filepath.Join(workingDir, candidate))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return devcontainer{}, fmt.Errorf("failed to read %s: %w", candidate, err)
+		}
+		path, data = candidate, content
+		break
+	}
+
+	if path == "" {
+		return devcontainer{}, nil
+	}
+
+	var config devcontainerConfig
+	if err := json.Unmarshal(stripJSONC(data), &config); err != nil {
+		return devcontainer{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	result := devcontainer{Path: path, SkippedEnv: map[string]string{}}
+
+	for _, name := range slices.Sorted(maps.Keys(config.ContainerEnv)) {
+		variable, err := devcontainerLaunchVariable(name, config.ContainerEnv[name], workingDir)
+		if err != nil {
+			result.SkippedEnv[name] = err.Error()
+			continue
+		}
+		result.Env = append(result.Env, variable)
+	}
+
+	for _, value := range config.ForwardPorts {
+		port, err := parseForwardedPort(value)
+		if err != nil {
+			return devcontainer{}, fmt.Errorf("%s: forwardPorts: %w", path, err)
+		}
+		result.Ports = append(result.Ports, port)
+	}
+
+	command, err := devcontainerCommand(config.PostStartCommand)
+	if err != nil {
+		return devcontainer{}, fmt.Errorf("%s: postStartCommand: %w", path, err)
+	}
+	result.PostStartCommand = command
+
+	if config.PostCreateCommand != nil {
+		result.Skipped = append(result.Skipped, "postCreateCommand")
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(config.Features)) {
+		name := featureName(id)
+		packages, ok := devcontainerFeatures[name]
+		if !ok {
+			if !slices.Contains(devcontainerNoopFeatures, name) {
+				result.Skipped = append(result.Skipped, "feature "+id)
+			}
+			continue
+		}
+
+		version := featureVersion(config.Features[id])
+		if version == "none" {
+			continue
+		}
+
+		for _, pkg := range packages {
+			if version != "" {
+				pkg += "@" + version
+			}
+			result.Packages = append(result.Packages, pkg)
+		}
+	}
+
+	return result, nil
+}
+
+// featureName turns a feature reference such as
+// "ghcr.io/devcontainers/features/node:1" into "node".
+func featureName(id string) string {
+	name := id[strings.LastIndex(id, "/")+1:]
+	if i := strings.IndexAny(name, ":@"); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+// featureVersion returns the version option of a feature when it pins one.
+// "latest", "lts" and "os-provided" leave the choice to pkgx.
+func featureVersion(options interface{}) string {
+	var version string
+	switch value := options.(type) {
+	case string:
+		version = value
+	case map[string]interface{}:
+		version, _ = value["version"].(string)
+	}
+
+	version = strings.TrimSpace(version)
+	switch version {
+	case "latest", "lts", "os-provided":
+		return ""
+	}
+
+	return version
+}
+
+var (
+	workspaceVariable    = regexp.MustCompile(`\$\{(containerWorkspaceFolder|containerWorkspaceFolderBasename|localWorkspaceFolder|localWorkspaceFolderBasename)\}`)
+	containerEnvVariable = regexp.MustCompile(`\$\{containerEnv:([^}:]+)(?::[^}]*)?\}`)
+)
+
+// devcontainerLaunchVariable turns a containerEnv entry into a launch
+// environment variable. The workspace folder variables are resolved at build
+// time. ${localEnv:...} is not: it would copy a value of the build
+// environment, a token say, into the image. The launcher does not expand
+// ${containerEnv:...} either, so only "${containerEnv:NAME}:value" and
+// "value:${containerEnv:NAME}" for the variable's own NAME are supported,
+// as an append to and a prepend to the container's value.
+func devcontainerLaunchVariable(name, value, workingDir string) (launchVariable, error) {
+	if strings.Contains(value, "${localEnv:") {
+		return launchVariable{}, errors.New("${localEnv:...} would copy a build-time value into the image")
+	}
+
+	value = workspaceVariable.ReplaceAllStringFunc(value, func(match string) string {
+		switch match[2 : len(match)-1] {
+		case "containerWorkspaceFolder", "localWorkspaceFolder":
+			return workingDir
+		default:
+			return filepath.Base(workingDir)
+		}
+	})
+
+	references := containerEnvVariable.FindAllStringSubmatchIndex(value, -1)
+	if len(references) == 0 {
+		return launchVariable{Name: name, Value: value, Mode: launchEnvDefault}, nil
+	}
+
+	if len(references) == 1 && value[references[0][2]:references[0][3]] == name {
+		start, end := references[0][0], references[0][1]
+		switch {
+		case start == 0 && strings.HasPrefix(value[end:], launchEnvDelimiter) && len(value) > end+1:
+			return launchVariable{Name: name, Value: value[end+1:], Mode: launchEnvAppend}, nil
+		case end == len(value) && strings.HasSuffix(value[:start], launchEnvDelimiter) && start > 1:
+			return launchVariable{Name: name, Value: value[:start-1], Mode: launchEnvPrepend}, nil
+		}
+	}
+
+	return launchVariable{}, fmt.Errorf("the launcher does not expand ${containerEnv:...}; only \"${containerEnv:%[1]s}:value\" and \"value:${containerEnv:%[1]s}\" are supported", name)
+}
+
+// parseForwardedPort accepts the forms forwardPorts allows: a port number,
+// or a "host:port" string.
+func parseForwardedPort(value interface{}) (forwardedPort, error) {
+	port := forwardedPort{Host: "127.0.0.1"}
+
+	var portText string
+	switch v := value.(type) {
+	case float64:
+		portText = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		portText = v
+		if host, p, ok := strings.Cut(v, ":"); ok {
+			port.Host, portText = host, p
+		}
+	default:
+		return forwardedPort{}, fmt.Errorf("expected a port number or \"host:port\", got %v", value)
+	}
+
+	number, err := strconv.Atoi(portText)
+	if err != nil || number < 1 || number > 65535 {
+		return forwardedPort{}, fmt.Errorf("invalid port %v", value)
+	}
+	port.Port = number
+
+	port.Label = strconv.Itoa(number)
+	if port.Host != "127.0.0.1" && port.Host != "localhost" {
+		port.Label = port.Host + "-" + port.Label
+	}
+
+	return port, nil
+}
+
+// devcontainerCommand turns a lifecycle command into a shell command line.
+// A string runs through the shell, an array is an argv, and an object runs
+// its commands in parallel, like VS Code does.
+func devcontainerCommand(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		argv := make([]string, 0, len(v))
+		for _, part := range v {
+			arg, ok := part.(string)
+			if !ok {
+				return "", fmt.Errorf("expected an array of strings")
+			}
+			argv = append(argv, shellQuote(arg))
+		}
+		return strings.Join(argv, " "), nil
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var commands []string
+		for _, name := range names {
+			command, err := devcontainerCommand(v[name])
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+			if command != "" {
+				commands = append(commands, "("+command+") &")
+			}
+		}
+		if len(commands) == 0 {
+			return "", nil
+		}
+		return strings.Join(append(commands, "wait"), " "), nil
+	default:
+		return "", fmt.Errorf("expected a string, an array or an object")
+	}
+}
+
+// writeCaddyRoutes writes a Caddyfile snippet proxying /ports/<label>/ to
+// each forwarded port. The Caddyfile imports it through
+// SUPERVISE_CADDY_ROUTES.
+func writeCaddyRoutes(ports []forwardedPort, destPath string) error {
+	var routes strings.Builder
+	for _, port := range ports {
+		prefix := devcontainerPortPrefix + port.Label
+		fmt.Fprintf(&routes, "redir %s %s/\n", prefix, prefix)
+		fmt.Fprintf(&routes, "handle_path %s/* {\n\treverse_proxy %s:%d\n}\n", prefix, port.Host, port.Port)
+	}
+
+	if err := os.WriteFile(destPath, []byte(routes.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write Caddy routes: %w", err)
+	}
+
+	return nil
+}
+
+// stripJSONC removes comments and trailing commas, the JSON with Comments
+// extensions devcontainer.json allows, leaving string contents untouched.
+func stripJSONC(data []byte) []byte {
+	return scanJSON(scanJSON(data, stripComment), stripTrailingComma)
+}
+
+// scanJSON copies data, letting skip decide outside of strings how many
+// bytes to drop at each position.
+func scanJSON(data []byte, skip func(data []byte, i int) int) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		if n := skip(data, i); n > 0 {
+			i += n - 1
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// stripComment returns the length of the // or /* */ comment at i. Line
+// comments keep their newline.
+func stripComment(data []byte, i int) int {
+	if data[i] != '/' || i+1 >= len(data) {
+		return 0
+	}
+
+	switch data[i+1] {
+	case '/':
+		end := i
+		for end < len(data) && data[end] != '\n' {
+			end++
+		}
+		return end - i
+	case '*':
+		end := i + 2
+		for end+1 < len(data) && !(data[end] == '*' && data[end+1] == '/') {
+			end++
+		}
+		return min(end+2, len(data)) - i
+	}
+
+	return 0
+}
+
+// stripTrailingComma drops a comma that only has whitespace before the
+// closing bracket.
+func stripTrailingComma(data []byte, i int) int {
+	if data[i] != ',' {
+		return 0
+	}
+
+	j := i + 1
+	for j < len(data) && strings.ContainsRune(" \t\r\n", rune(data[j])) {
+		j++
+	}
+
+	if j < len(data) && (data[j] == '}' || data[j] == ']') {
+		return 1
+	}
+
+	return 0
+}
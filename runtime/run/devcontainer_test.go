@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func TestDevcontainerLaunchVariable(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable string
+		value    string
+		want     launchVariable
+		wantErr  bool
+	}{
+		{
+			name:     "plain value",
+			variable: "LOG_LEVEL",
+			value:    "debug",
+			want:     launchVariable{Name: "LOG_LEVEL", Value: "debug", Mode: launchEnvDefault},
+		},
+		{
+			name:     "workspace folder",
+			variable: "CACHE_DIR",
+			value:    "${containerWorkspaceFolder}/tmp/${localWorkspaceFolderBasename}",
+			want:     launchVariable{Name: "CACHE_DIR", Value: "/workspace/app/tmp/app", Mode: launchEnvDefault},
+		},
+		{
+			name:     "appends to the container value",
+			variable: "PATH",
+			value:    "${containerEnv:PATH}:${containerWorkspaceFolder}/bin",
+			want:     launchVariable{Name: "PATH", Value: "/workspace/app/bin", Mode: launchEnvAppend},
+		},
+		{
+			name:     "prepends to the container value",
+			variable: "PATH",
+			value:    "/opt/tools/bin:/usr/local/go/bin:${containerEnv:PATH}",
+			want:     launchVariable{Name: "PATH", Value: "/opt/tools/bin:/usr/local/go/bin", Mode: launchEnvPrepend},
+		},
+		{
+			name:     "localEnv is not copied into the image",
+			variable: "GITHUB_TOKEN",
+			value:    "${localEnv:GITHUB_TOKEN}",
+			wantErr:  true,
+		},
+		{
+			name:     "localEnv with a default",
+			variable: "REGION",
+			value:    "${localEnv:AWS_REGION:us-east-1}",
+			wantErr:  true,
+		},
+		{
+			name:     "another variable's container value",
+			variable: "GOPATH",
+			value:    "${containerEnv:HOME}/go",
+			wantErr:  true,
+		},
+		{
+			name:     "container value in the middle",
+			variable: "PATH",
+			value:    "/a:${containerEnv:PATH}:/b",
+			wantErr:  true,
+		},
+		{
+			name:     "container value alone",
+			variable: "PATH",
+			value:    "${containerEnv:PATH}",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := devcontainerLaunchVariable(tt.variable, tt.value, "/workspace/app")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("devcontainerLaunchVariable = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("devcontainerLaunchVariable = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dotenvNames are the env files the runtime picks up from the app directory,
+// in precedence order.
+//
+// They are read again each time a generated process starts, and only set
+// variables that are still unset. So the precedence, highest first, is:
+// runtime.process_env bindings and env files, then the container environment
+// (including the buildpacks' LaunchEnv) and a process's environment from
+// process-compose.yaml, then .env.development, then .env. process-compose
+// itself is started with --disable-dotenv: it would otherwise load .env into
+// its own environment, ahead of .env.development and past this precedence.
+var dotenvNames = []string{".env.development", ".env"}
+
+type dotenvEntry struct {
+	Key   string
+	Value string
+}
+
+// readDotenvFiles parses the app's .env files so that mistakes fail the build
+// rather than the process, and returns the absolute paths of those present.
+// The values themselves are not kept: supervise-env.sh reads the files at
+// launch, so edits apply on the next process restart.
+func readDotenvFiles(workingDir string) ([]string, map[string][]dotenvEntry, error) {
+	var paths []string
+	entries := map[string][]dotenvEntry{}
+	for _, name := range dotenvNames {
+		path := filepath.Join(workingDir, name)
+
+		file, err := os.Open(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+
+		parsed, err := parseDotenv(name, file)
+		file.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse env file: %w", err)
+		}
+
+		paths = append(paths, path)
+		entries[name] = parsed
+	}
+
+	return paths, entries, nil
+}
+
+// parseDotenv reads KEY=value lines, optionally prefixed with "export".
+// Blank lines and lines starting with # are skipped. Values are unquoted
+// (ending at a # preceded by whitespace), single-quoted (literal) or
+// double-quoted, where a backslash escapes the next character and \n is a
+// newline. Values cannot span lines and ${VAR} is not expanded. This is the
+// grammar supervise-env.sh implements; errors name the file and line.
+func parseDotenv(name string, file *os.File) ([]dotenvEntry, error) {
+	var entries []dotenvEntry
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseDotenvLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, number, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return entries, nil
+}
+
+func parseDotenvLine(line string) (dotenvEntry, error) {
+	if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		line = strings.TrimSpace(rest)
+	}
+
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return dotenvEntry{}, errors.New("expected KEY=value")
+	}
+
+	key = strings.TrimRight(key, " \t")
+	if !envVariableName.MatchString(key) {
+		return dotenvEntry{}, fmt.Errorf("invalid variable name %q", key)
+	}
+
+	value = strings.TrimLeft(value, " \t")
+
+	var after string
+	switch {
+	case strings.HasPrefix(value, "'"):
+		content, rest, ok := strings.Cut(value[1:], "'")
+		if !ok {
+			return dotenvEntry{}, fmt.Errorf("unterminated single-quoted value for %s", key)
+		}
+		value, after = content, rest
+
+	case strings.HasPrefix(value, `"`):
+		var builder strings.Builder
+		rest := value[1:]
+		for {
+			if rest == "" {
+				return dotenvEntry{}, fmt.Errorf("unterminated double-quoted value for %s", key)
+			}
+
+			c := rest[0]
+			rest = rest[1:]
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				if rest == "" {
+					return dotenvEntry{}, fmt.Errorf("unterminated double-quoted value for %s", key)
+				}
+				c = rest[0]
+				rest = rest[1:]
+				if c == 'n' {
+					c = '\n'
+				}
+			}
+			builder.WriteByte(c)
+		}
+		value, after = builder.String(), rest
+
+	default:
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				value = value[:i]
+				break
+			}
+		}
+		value = strings.TrimRight(value, " \t")
+	}
+
+	after = strings.TrimLeft(after, " \t")
+	if after != "" && !strings.HasPrefix(after, "#") {
+		return dotenvEntry{}, fmt.Errorf("unexpected %q after the quoted value for %s", after, key)
+	}
+
+	return dotenvEntry{Key: key, Value: value}, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dotenvCases pin the grammar parseDotenvLine and supervise-env.sh share. An
+// empty wantErr expects the value, otherwise an error containing wantErr.
+var dotenvCases = []struct {
+	name    string
+	line    string
+	key     string
+	want    string
+	wantErr string
+}{
+	{name: "plain", line: "KEY=value", key: "KEY", want: "value"},
+	{name: "export prefix", line: "export KEY=value", key: "KEY", want: "value"},
+	{name: "export-like name", line: "exportKEY=value", key: "exportKEY", want: "value"},
+	{name: "blanks around the equals sign", line: "KEY =  value", key: "KEY", want: "value"},
+	{name: "empty value", line: "KEY=", key: "KEY", want: ""},
+	{name: "trailing comment", line: "KEY=value # comment", key: "KEY", want: "value"},
+	{name: "hash inside a value", line: "KEY=a#b", key: "KEY", want: "a#b"},
+	{name: "no expansion", line: "KEY=${HOME}/x", key: "KEY", want: "${HOME}/x"},
+	{name: "single quotes are literal", line: `KEY='a # "b" \n'`, key: "KEY", want: `a # "b" \n`},
+	{name: "double quotes with escapes", line: `KEY="a \"b\" \\ c\nd"`, key: "KEY", want: "a \"b\" \\ c\nd"},
+	{name: "comment after quotes", line: `KEY="value" # comment`, key: "KEY", want: "value"},
+	{name: "missing equals sign", line: "KEY", key: "KEY", wantErr: "expected KEY=value"},
+	{name: "name starting with a digit", line: "1KEY=value", key: "1KEY", wantErr: `invalid variable name "1KEY"`},
+	{name: "name with a dash", line: "MY-KEY=value", key: "MY_KEY", wantErr: `invalid variable name "MY-KEY"`},
+	{name: "unterminated single quote", line: "KEY='value", key: "KEY", wantErr: "unterminated single-quoted value for KEY"},
+	{name: "unterminated double quote", line: `KEY="value`, key: "KEY", wantErr: "unterminated double-quoted value for KEY"},
+	{name: "trailing backslash", line: `KEY="value\`, key: "KEY", wantErr: "unterminated double-quoted value for KEY"},
+	{name: "text after quotes", line: `KEY="a" b`, key: "KEY", wantErr: `unexpected "b" after the quoted value for KEY`},
+}
+
+func TestParseDotenvLine(t *testing.T) {
+	for _, tt := range dotenvCases {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parseDotenvLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseDotenvLine(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if entry != (dotenvEntry{Key: tt.key, Value: tt.want}) {
+				t.Errorf("parseDotenvLine(%q) = %+v, want %s=%q", tt.line, entry, tt.key, tt.want)
+			}
+		})
+	}
+}
+
+// TestSuperviseEnvDotenv runs the launch script under bash on the same cases,
+// so the build-time check and the launch-time parser agree.
+func TestSuperviseEnvDotenv(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	script, err := filepath.Abs(filepath.Join("..", "config", envScriptName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range dotenvCases {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(file, []byte(tt.line+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command(bash, "-c", `set -- test --dotenv "$DOTENV" && . "$SCRIPT" || exit $?; printf %s "${`+tt.key+`-unset}"`)
+			cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "DOTENV=" + file, "SCRIPT=" + script}
+
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			output, err := cmd.Output()
+
+			if tt.wantErr != "" {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) || exitErr.ExitCode() != 65 {
+					t.Fatalf("script = %q, %v, want exit status 65", output, err)
+				}
+				if !strings.Contains(stderr.String(), tt.wantErr) {
+					t.Errorf("script error = %q, want %q", stderr.String(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("script failed: %v: %s", err, stderr.String())
+			}
+
+			if string(output) != tt.want {
+				t.Errorf("script set %s=%q, want %q", tt.key, output, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+const (
+	envScriptName = "supervise-env.sh"
+
+	// envBindingType is the service binding type whose entries the runtime
+	// exports as environment variables at launch.
+	envBindingType = "supervise-env"
+)
+
+var (
+	envVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	bindingName     = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// processEnv resolves runtime.process_env against the app: env files become
+// absolute paths in the app directory unless they already are absolute, and
+// names are checked so that the launch script can take them as arguments.
+// Values are not read here; the script reads bindings and env files when the
+// process starts.
+func processEnv(workingDir string, settings map[string]supervise.ProcessEnvSettings) (map[string]supervise.ProcessEnvSettings, error) {
+	resolved := map[string]supervise.ProcessEnvSettings{}
+	for name, env := range settings {
+		for _, binding := range env.Bindings {
+			if !bindingName.MatchString(binding) {
+				return nil, fmt.Errorf("runtime.process_env.%s: invalid binding name %q", name, binding)
+			}
+		}
+
+		var files []string
+		for _, file := range env.EnvFiles {
+			if file == "" || strings.ContainsRune(file, '\n') {
+				return nil, fmt.Errorf("runtime.process_env.%s: invalid env file %q", name, file)
+			}
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(workingDir, file)
+			}
+			files = append(files, file)
+		}
+
+		for key := range env.Environment {
+			if !envVariableName.MatchString(key) {
+				return nil, fmt.Errorf("runtime.process_env.%s: invalid variable name %q", name, key)
+			}
+		}
+
+		env.EnvFiles = files
+		resolved[name] = env
+	}
+
+	return resolved, nil
+}
+
+// claimedBindings lists the bindings some process names explicitly; the
+// launch script keeps them from the processes that do not.
+func claimedBindings(settings map[string]supervise.ProcessEnvSettings) []string {
+	var claimed []string
+	for _, env := range settings {
+		for _, binding := range env.Bindings {
+			if !slices.Contains(claimed, binding) {
+				claimed = append(claimed, binding)
+			}
+		}
+	}
+
+	slices.Sort(claimed)
+
+	return claimed
+}
+
+// withProcessEnv prefixes a generated process's command with the launch
+// script and adds its plain environment values to the process entry.
+func withProcessEnv(entry processEntry, name, scriptPath string, env supervise.ProcessEnvSettings, claimed, dotenv []string) processEntry {
+	args := []string{"set", "--", shellQuote(name)}
+	if len(claimed) > 0 {
+		args = append(args, "--claimed", shellQuote(strings.Join(claimed, ",")))
+	}
+	for _, binding := range env.Bindings {
+		args = append(args, "--binding", shellQuote(binding))
+	}
+	for _, file := range env.EnvFiles {
+		args = append(args, "--file", shellQuote(file))
+	}
+	for _, file := range dotenv {
+		args = append(args, "--dotenv", shellQuote(file))
+	}
+
+	// Arguments to a sourced script are a bash extension, hence the set --.
+	// The braces keep a command list together behind the &&, and the newline
+	// ends a trailing comment in the original command.
+	entry.Command = fmt.Sprintf("%s && . %s && {\n%s\n}", strings.Join(args, " "), shellQuote(scriptPath), entry.Command)
+
+	for _, key := range slices.Sorted(maps.Keys(env.Environment)) {
+		entry.Environment = append(entry.Environment, key+"="+env.Environment[key])
+	}
+
+	return entry
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"cmp"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/supervise"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,17 +20,112 @@ const (
 	layerName              = "runtime"
 	defaultCaddyConfigPath = "/layers/dev.supervise.caddy/caddy/config/Caddyfile"
 	defaultCaddyBinaryPath = "/layers/dev.supervise.caddy/caddy/bin/caddy"
+	caddyConfigPathEnv     = "BP_SUPERVISE_CADDY_CONFIG_PATH"
+	devProbeEnv            = "BP_SUPERVISE_DEV_PROBE"
+	ttydSocketPath         = "/tmp/ttyd/ttyd.sock"
+	watchSocketPath        = "/tmp/ttyd/watch.sock"
+	tmuxSocketPath         = "/tmp/tmux-supervise.sock"
+	agentCommandEnv        = "SUPERVISE_AGENT_COMMAND"
+
+	// defaultAgentCommand is pinned so rebuilding an image does not silently
+	// pick up a new agent release.
+	defaultAgentCommand = "pkgx npx @anthropic-ai/claude-code@2.0.0"
+
+	// processComposeVersion is the process-compose release pkgx runs, pinned
+	// for the same reason and recorded in the SBOM.
+	processComposeVersion = "1.64.1"
 )
 
+// optionalComponents lists the Supervise components that can be left out of
+// the image through the disable list. pkgx is not among them because
+// process-compose itself runs through it.
+var optionalComponents = []string{"caddy", "ttyd"}
+
 func main() {
 	packit.Run(detect, build)
 }
 
 func detect(context packit.DetectContext) (packit.DetectResult, error) {
 	// Always pass detection - runtime is always required
-	// Attempt to make working directory writable, but don't fail if it errors
-	_ = exec.Command("chmod", "-R", "a+w", context.WorkingDir).Run()
-	return packit.DetectResult{}, nil
+	config, _, err := supervise.LoadConfig(context.WorkingDir)
+	if err != nil {
+		return packit.DetectResult{}, err
+	}
+
+	disabled, err := disabledComponents(config.Disable)
+	if err != nil {
+		return packit.DetectResult{}, err
+	}
+
+	// process-compose runs the caddy and ttyd binaries, and is itself run
+	// through pkgx, so all of them have to be present in the launch image.
+	launch := map[string]interface{}{"launch": true}
+
+	requires := []packit.BuildPlanRequirement{
+		{Name: "pkgx", Metadata: launch},
+	}
+	for _, component := range optionalComponents {
+		if !disabled[component] {
+			requires = append(requires, packit.BuildPlanRequirement{Name: component, Metadata: launch})
+		}
+	}
+
+	return packit.DetectResult{
+		Plan: packit.BuildPlan{
+			Requires: requires,
+		},
+	}, nil
+}
+
+// disabledComponents validates the disable list of the Supervise config (or
+// BP_SUPERVISE_DISABLE), the optional components to leave out of the image.
+func disabledComponents(disable []string) (map[string]bool, error) {
+	disabled := map[string]bool{}
+	for _, component := range disable {
+		component = strings.ToLower(strings.TrimSpace(component))
+		if component == "" {
+			continue
+		}
+
+		if !slices.Contains(optionalComponents, component) {
+			return nil, fmt.Errorf("disable: unknown component %q, expected one of %s", component, strings.Join(optionalComponents, ", "))
+		}
+
+		disabled[component] = true
+	}
+
+	return disabled, nil
+}
+
+// loadSettings returns the Supervise config with the runtime section
+// completed by the defaults and the environment overrides.
+func loadSettings(workingDir string) (supervise.Config, supervise.RuntimeSettings, string, error) {
+	config, source, err := supervise.LoadConfig(workingDir)
+	if err != nil {
+		return supervise.Config{}, supervise.RuntimeSettings{}, "", err
+	}
+
+	settings := config.Runtime
+	if settings.CaddyConfigPath == "" {
+		settings.CaddyConfigPath = defaultCaddyConfigPath
+	}
+	if settings.Restart == "" {
+		settings.Restart = fmt.Sprintf("%s:%d:%d", defaultRestartPolicy, defaultBackoffSeconds, defaultMaxRestarts)
+	}
+	if settings.WritableMode == "" {
+		settings.WritableMode = fmt.Sprintf("%#o", defaultWritableMode)
+	}
+
+	supervise.OverrideString(caddyConfigPathEnv, &settings.CaddyConfigPath)
+	supervise.OverrideString(agentCommandEnv, &settings.AgentCommand)
+	supervise.OverrideString(restartEnv, &settings.Restart)
+	supervise.OverrideList(writablePathsEnv, &settings.WritablePaths)
+	supervise.OverrideString(writableModeEnv, &settings.WritableMode)
+	if err := supervise.OverrideBool(devProbeEnv, &settings.DevProbe); err != nil {
+		return supervise.Config{}, supervise.RuntimeSettings{}, "", err
+	}
+
+	return config, settings, source, nil
 }
 
 func build(context packit.BuildContext) (packit.BuildResult, error) {
@@ -57,32 +155,226 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 		return packit.BuildResult{}, fmt.Errorf("failed to create process-compose config home: %w", err)
 	}
 
-	// Copy agent.sh script
-	agentScriptSrc := filepath.Join(context.CNBPath, "scripts", "agent.sh")
-	agentScriptDst := filepath.Join(binDir, "agent.sh")
+	superviseConfig, settings, source, err := loadSettings(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
 
-	if err := copyFile(agentScriptSrc, agentScriptDst); err != nil {
-		return packit.BuildResult{}, fmt.Errorf("failed to copy agent.sh: %w", err)
+	supervise.PrintConfig(source, [][2]string{
+		{"disable", strings.Join(superviseConfig.Disable, ", ")},
+		{"runtime.caddy_config_path", settings.CaddyConfigPath},
+		{"runtime.agent_command", settings.AgentCommand},
+		{"runtime.dev_probe", strconv.FormatBool(settings.DevProbe)},
+		{"runtime.restart", settings.Restart},
+		{"runtime.writable_paths", strings.Join(settings.WritablePaths, ", ")},
+		{"runtime.writable_mode", settings.WritableMode},
+		{"runtime.process_compose", settings.ProcessCompose},
+	})
+
+	disabled, err := disabledComponents(superviseConfig.Disable)
+	if err != nil {
+		return packit.BuildResult{}, err
 	}
 
-	if err := os.Chmod(agentScriptDst, 0o755); err != nil {
-		return packit.BuildResult{}, fmt.Errorf("failed to make agent.sh executable: %w", err)
+	// The agent and the dev process run as the user that owns the app files;
+	// only the configured paths get extra permission bits for other users.
+	writablePaths, writableMode, err := writableConfig(settings)
+	if err != nil {
+		return packit.BuildResult{}, err
 	}
 
-	// Read dev process from Procfile
-	devCommand, err := readDevProcess(context.WorkingDir)
+	report, err := makeWritable(context.WorkingDir, writablePaths, writableMode)
 	if err != nil {
-		return packit.BuildResult{}, fmt.Errorf("failed to read dev process: %w", err)
+		return packit.BuildResult{}, err
+	}
+
+	if len(writablePaths) == 0 || writableMode == 0 {
+		fmt.Println("No writable paths configured, app permissions left unchanged")
+	} else {
+		fmt.Printf("Made app paths writable (%#o added to %s): %d changed, %d already writable, %d symlinks skipped, %d read-only skipped\n",
+			writableMode, strings.Join(writablePaths, ", "), report.Changed, report.Unchanged, report.Symlinks, report.ReadOnly)
+		for _, path := range report.Missing {
+			fmt.Printf("  Skipped missing path %s\n", path)
+		}
 	}
 
 	processComposePath := filepath.Join(configDir, "process-compose.yaml")
-	if err := writeProcessComposeConfig(
+	sbomFiles := []string{processComposePath}
+
+	// Bindings and env files are read by this script when each process
+	// starts, so their values stay out of the image.
+	envScriptPath := filepath.Join(binDir, envScriptName)
+	if err := copyFile(filepath.Join(context.CNBPath, "config", envScriptName), envScriptPath); err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to copy %s: %w", envScriptName, err)
+	}
+	sbomFiles = append(sbomFiles, envScriptPath)
+
+	env, err := processEnv(context.WorkingDir, settings.ProcessEnv)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	dotenv, dotenvEntries, err := readDotenvFiles(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	// The agent launcher serves the terminal through ttyd, so it is only
+	// installed when ttyd is part of the image. It is built alongside the
+	// buildpack's own binaries (see the Makefile).
+	agentBinaryDst := ""
+	sessionsPath := ""
+	ttydConfigPath := ""
+	if !disabled["ttyd"] {
+		agentBinarySrc := filepath.Join(context.CNBPath, "bin", "agent")
+		agentBinaryDst = filepath.Join(binDir, "agent")
+
+		if err := copyFile(agentBinarySrc, agentBinaryDst); err != nil {
+			return packit.BuildResult{}, fmt.Errorf("failed to copy agent launcher: %w", err)
+		}
+
+		if err := os.Chmod(agentBinaryDst, 0o755); err != nil {
+			return packit.BuildResult{}, fmt.Errorf("failed to make agent launcher executable: %w", err)
+		}
+
+		sbomFiles = append(sbomFiles, agentBinaryDst)
+
+		sessionsPath = filepath.Join(configDir, "sessions.json")
+		sessions, err := writeSessions(context.WorkingDir, sessionsPath, settings.Sessions)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		for _, session := range sessions {
+			fmt.Printf("  Terminal session %s: %s\n", session.Name, session.Command)
+		}
+
+		ttydConfigPath = filepath.Join(configDir, "ttyd.json")
+		ttydConfig, err := writeTTYDConfig(context.WorkingDir, ttydConfigPath, settings.Terminal)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		fmt.Printf("  Terminal: writable=%t max_clients=%d once=%t ping_interval=%d client_options=%v\n",
+			ttydConfig.Writable, ttydConfig.MaxClients, ttydConfig.Once, ttydConfig.PingInterval, ttydConfig.ClientOptions)
+	}
+
+	caddyConfigPath := settings.CaddyConfigPath
+	if disabled["caddy"] {
+		caddyConfigPath = ""
+	}
+
+	procfile, err := readProcfile(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+
+	// The command run in the agent terminal comes from SUPERVISE_AGENT_COMMAND
+	// at build time or runtime.agent_command, then a Procfile agent entry,
+	// then the pinned default. It is only a launch default, so setting
+	// SUPERVISE_AGENT_COMMAND on the container still wins.
+	agentCommand := cmp.Or(
+		settings.AgentCommand,
+		procfileCommand(procfile, agentProcessType),
+		defaultAgentCommand,
+	)
+	procfile = withoutProcess(procfile, agentProcessType)
+
+	// Without an explicit dev or web command, supervise the web process the
+	// language buildpack earlier in the group derived from the app.
+	if procfileCommand(procfile, defaultProcessType) == "" && procfileCommand(procfile, upstreamProcessType) == "" {
+		upstream, ok, err := readUpstreamProcess(context.Layers.Path, upstreamProcessType)
+		if err != nil {
+			return packit.BuildResult{}, fmt.Errorf("failed to read upstream launch processes: %w", err)
+		}
+
+		if ok {
+			fmt.Printf("No Procfile dev process, using %s process from %s: %s\n", upstream.Type, upstream.Buildpack, upstream.Command)
+			procfile = append(procfile, procfileProcess{
+				Type:    defaultProcessType,
+				Command: upstream.Command,
+				Source:  upstream.Buildpack,
+			})
+		}
+	}
+
+	devcontainer, err := readDevcontainer(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	caddyRoutesPath := ""
+	if devcontainer.Path != "" {
+		fmt.Printf("Using dev container settings from %s\n", devcontainer.Path)
+		for _, skipped := range devcontainer.Skipped {
+			fmt.Printf("  Skipped %s: not supported by the buildpack\n", skipped)
+		}
+		for _, name := range slices.Sorted(maps.Keys(devcontainer.SkippedEnv)) {
+			fmt.Printf("  Skipped containerEnv %s: %s\n", name, devcontainer.SkippedEnv[name])
+		}
+
+		if len(devcontainer.Ports) > 0 {
+			if caddyConfigPath == "" {
+				fmt.Println("  Skipped forwardPorts: caddy is disabled")
+			} else {
+				caddyRoutesPath = filepath.Join(configDir, "routes.caddy")
+				if err := writeCaddyRoutes(devcontainer.Ports, caddyRoutesPath); err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				for _, port := range devcontainer.Ports {
+					fmt.Printf("  Forwarded port %s:%d at %s%s/\n", port.Host, port.Port, devcontainerPortPrefix, port.Label)
+				}
+			}
+		}
+
+		if len(devcontainer.Packages) > 0 {
+			fmt.Printf("  pkgx packages for features: %s\n", strings.Join(devcontainer.Packages, " "))
+		}
+	}
+
+	appConfig, appConfigPath, err := loadAppProcessCompose(context.WorkingDir, settings.ProcessCompose)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	if appConfigPath != "" {
+		fmt.Printf("Merging app process-compose config from %s\n", appConfigPath)
+	}
+
+	restarts, err := readRestartPolicies(settings.Restart)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	config, err := writeProcessComposeConfig(
 		filepath.Join(context.CNBPath, "config", "process-compose.yaml"),
 		processComposePath,
-		devCommand,
-		agentScriptDst,
-		defaultCaddyConfigPath,
-	); err != nil {
+		processComposeOptions{
+			AppConfig:       appConfig,
+			Procfile:        procfile,
+			DevProbe:        settings.DevProbe,
+			AgentCommand:    agentBinaryDst,
+			SessionsPath:    sessionsPath,
+			TTYDConfigPath:  ttydConfigPath,
+			CaddyConfigPath: caddyConfigPath,
+			Restarts:        restarts,
+			PostStart: procfileProcess{
+				Type:    postStartProcessType,
+				Command: devcontainer.PostStartCommand,
+				Source:  devcontainer.Path,
+			},
+			EnvScriptPath: envScriptPath,
+			ProcessEnv:    env,
+			Dotenv:        dotenv,
+		},
+	)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	layer.SBOM, err = runtimeSBOM(context.BuildpackInfo, layer.Path, sbomFiles...)
+	if err != nil {
 		return packit.BuildResult{}, err
 	}
 
@@ -94,61 +386,125 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 	layer.LaunchEnv.Default("TERM", "xterm-256color")
 	layer.LaunchEnv.Default("PC_DISABLE_TUI", "1")
 	layer.LaunchEnv.Default("PC_LOG_FILE", "/tmp/process-compose.log")
-	layer.LaunchEnv.Default("CADDY_CONFIG", defaultCaddyConfigPath)
+	if caddyConfigPath != "" {
+		layer.LaunchEnv.Default("CADDY_CONFIG", caddyConfigPath)
+	}
+	if agentBinaryDst != "" {
+		layer.LaunchEnv.Default(agentCommandEnv, agentCommand)
+	}
+	if caddyRoutesPath != "" {
+		layer.LaunchEnv.Default(caddyRoutesEnv, caddyRoutesPath)
+	}
+	for _, variable := range devcontainer.Env {
+		switch variable.Mode {
+		case launchEnvPrepend:
+			layer.LaunchEnv.Prepend(variable.Name, variable.Value, launchEnvDelimiter)
+		case launchEnvAppend:
+			layer.LaunchEnv.Append(variable.Name, variable.Value, launchEnvDelimiter)
+		default:
+			layer.LaunchEnv.Default(variable.Name, variable.Value)
+		}
+	}
+
+	disabledList := strings.Join(slices.Sorted(maps.Keys(disabled)), ",")
+
+	devCommand := procfileCommand(procfile, defaultProcessType)
+
+	procfileTypes := make([]string, 0, len(procfile))
+	for _, process := range procfile {
+		procfileTypes = append(procfileTypes, process.Type)
+	}
 
 	layer.Metadata = map[string]interface{}{
-		"dev_command": devCommand,
+		"dev_command":        devCommand,
+		"agent_command":      agentCommand,
+		"procfile_processes": strings.Join(procfileTypes, ","),
+		"disabled":           disabledList,
+		"devcontainer":       devcontainer.Path,
+		"pkgx_packages":      strings.Join(devcontainer.Packages, " "),
 	}
 
 	fmt.Printf("Successfully installed runtime with dev process: %s\n", devCommand)
+	if agentBinaryDst != "" {
+		fmt.Printf("  Agent command: %s\n", agentCommand)
+	}
+	for _, process := range procfile {
+		fmt.Printf("  %s process %s: %s\n", process.Source, process.Type, process.Command)
+	}
+	if disabledList != "" {
+		fmt.Printf("Disabled components: %s\n", disabledList)
+	}
+	for _, name := range dotenvNames {
+		if entries, ok := dotenvEntries[name]; ok {
+			fmt.Printf("  Environment from %s: %d variables, read at launch where unset\n", name, len(entries))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		fmt.Printf("  Environment for %s: bindings [%s], env files [%s], %d values\n", name,
+			strings.Join(env[name].Bindings, ", "), strings.Join(env[name].EnvFiles, ", "), len(env[name].Environment))
+	}
 
-	// Define the process type that will run process-compose via pkgx
-	processComposeCommand := []string{"pkgx"}
-	processComposeArgs := []string{"process-compose", "--tui=false", "-f", processComposePath}
+	// Everything that is not a Procfile entry (agent, caddy and processes from
+	// the app's process-compose.yaml) runs alongside whichever type is launched.
+	var shared []string
+	for _, name := range slices.Sorted(maps.Keys(config.Processes)) {
+		if procfileCommand(procfile, name) == "" {
+			shared = append(shared, name)
+		}
+	}
 
 	return packit.BuildResult{
 		Layers: []packit.Layer{layer},
 		Launch: packit.LaunchMetadata{
-			DirectProcesses: []packit.DirectProcess{
-				{
-					Type:    "dev",
-					Command: processComposeCommand,
-					Args:    processComposeArgs,
-					Default: true,
-				},
-			},
+			DirectProcesses: launchProcesses(processComposePath, procfile, shared, devcontainer.Packages),
 		},
 	}, nil
 }
 
-func readDevProcess(workingDir string) (string, error) {
-	// Look for Procfile in working directory
-	procfilePath := filepath.Join(workingDir, "Procfile")
-
-	file, err := os.Open(procfilePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", nil // No Procfile found, return empty string
+// launchProcesses registers a CNB process type for every Procfile entry, each
+// running process-compose with only that process and the shared processes.
+// The dev type is always present and is the default; without a Procfile dev
+// entry it runs every configured process. The pkgx packages (from dev
+// container features) are added to the environment process-compose and
+// everything it starts inherit. process-compose runs with --disable-dotenv,
+// see dotenvNames.
+func launchProcesses(processComposePath string, procfile []procfileProcess, shared, packages []string) []packit.DirectProcess {
+	processComposeArgs := func(names ...string) []string {
+		var args []string
+		for _, pkg := range packages {
+			args = append(args, "+"+pkg)
 		}
-		return "", fmt.Errorf("failed to open Procfile: %w", err)
+		args = append(args, "process-compose@"+processComposeVersion)
+		if len(names) > 0 {
+			args = append(args, "up")
+		}
+		args = append(args, "--tui=false", "--disable-dotenv", "-f", processComposePath)
+		return append(args, names...)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "dev:") {
-			// Extract command after "dev:"
-			command := strings.TrimSpace(strings.TrimPrefix(line, "dev:"))
-			return command, nil
-		}
+	dev := packit.DirectProcess{
+		Type:    defaultProcessType,
+		Command: []string{"pkgx"},
+		Args:    processComposeArgs(),
+		Default: true,
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("failed to scan Procfile: %w", err)
+	processes := []packit.DirectProcess{dev}
+	for _, process := range procfile {
+		names := append([]string{process.Type}, shared...)
+		if process.Type == defaultProcessType {
+			processes[0].Args = processComposeArgs(names...)
+			continue
+		}
+
+		processes = append(processes, packit.DirectProcess{
+			Type:    process.Type,
+			Command: []string{"pkgx"},
+			Args:    processComposeArgs(names...),
+		})
 	}
 
-	return "", nil // No dev process found
+	return processes
 }
 
 func copyFile(src, dst string) error {
@@ -161,24 +517,120 @@ func copyFile(src, dst string) error {
 
 type processConfig struct {
 	Processes map[string]processEntry `yaml:"processes"`
+
+	// Extra keeps top-level settings such as environment or log_location.
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 type dependencyConfig struct {
 	Condition string `yaml:"condition,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
+// processEntry models a process-compose process. Keys without a field here
+// are kept in Extra, so settings from templates and app configs survive the
+// round trip through the runtime.
 type processEntry struct {
-	Description string                      `yaml:"description,omitempty"`
-	Command     string                      `yaml:"command"`
-	Args        []string                    `yaml:"args,omitempty"`
-	DependsOn   map[string]dependencyConfig `yaml:"depends_on,omitempty"`
-	Environment []string                    `yaml:"environment,omitempty"`
+	Description    string                      `yaml:"description,omitempty"`
+	Command        string                      `yaml:"command"`
+	Args           []string                    `yaml:"args,omitempty"`
+	WorkingDir     string                      `yaml:"working_dir,omitempty"`
+	Namespace      string                      `yaml:"namespace,omitempty"`
+	DependsOn      map[string]dependencyConfig `yaml:"depends_on,omitempty"`
+	Environment    []string                    `yaml:"environment,omitempty"`
+	LogLocation    string                      `yaml:"log_location,omitempty"`
+	Availability   *availabilityConfig         `yaml:"availability,omitempty"`
+	ReadinessProbe *probeConfig                `yaml:"readiness_probe,omitempty"`
+	LivenessProbe  *probeConfig                `yaml:"liveness_probe,omitempty"`
+	Shutdown       *shutdownConfig             `yaml:"shutdown,omitempty"`
+	Disabled       bool                        `yaml:"disabled,omitempty"`
+	IsDaemon       bool                        `yaml:"is_daemon,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
-func writeProcessComposeConfig(templatePath, destPath, devCommand, agentCommand, caddyConfigPath string) error {
+type availabilityConfig struct {
+	Restart        string `yaml:"restart,omitempty"`
+	BackoffSeconds int    `yaml:"backoff_seconds,omitempty"`
+	MaxRestarts    int    `yaml:"max_restarts,omitempty"`
+	ExitOnEnd      bool   `yaml:"exit_on_end,omitempty"`
+	ExitOnSkipped  bool   `yaml:"exit_on_skipped,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type probeConfig struct {
+	Exec                *execProbe    `yaml:"exec,omitempty"`
+	HTTPGet             *httpGetProbe `yaml:"http_get,omitempty"`
+	InitialDelaySeconds int           `yaml:"initial_delay_seconds,omitempty"`
+	PeriodSeconds       int           `yaml:"period_seconds,omitempty"`
+	TimeoutSeconds      int           `yaml:"timeout_seconds,omitempty"`
+	SuccessThreshold    int           `yaml:"success_threshold,omitempty"`
+	FailureThreshold    int           `yaml:"failure_threshold,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type execProbe struct {
+	Command    string `yaml:"command"`
+	WorkingDir string `yaml:"working_dir,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type httpGetProbe struct {
+	Host   string `yaml:"host,omitempty"`
+	Scheme string `yaml:"scheme,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	Port   int    `yaml:"port,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type shutdownConfig struct {
+	Command        string `yaml:"command,omitempty"`
+	Signal         int    `yaml:"signal,omitempty"`
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"`
+	ParentOnly     bool   `yaml:"parent_only,omitempty"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// processComposeOptions carries what the runtime generates on top of the
+// template: the app's own config, the Procfile processes, and the Supervise
+// components. An empty AgentCommand or CaddyConfigPath leaves that component
+// out.
+type processComposeOptions struct {
+	AppConfig       processConfig
+	Procfile        []procfileProcess
+	DevProbe        bool
+	AgentCommand    string
+	SessionsPath    string
+	TTYDConfigPath  string
+	CaddyConfigPath string
+	Restarts        restartPolicies
+	PostStart       procfileProcess // from devcontainer.json; empty Command for none
+
+	// EnvScriptPath is sourced before every generated process command to
+	// export its bindings and env files; ProcessEnv declares them by process
+	// and Dotenv lists the app's .env files, which apply to all of them.
+	EnvScriptPath string
+	ProcessEnv    map[string]supervise.ProcessEnvSettings
+	Dotenv        []string
+}
+
+// writeProcessComposeConfig layers three sources, later ones winning: the
+// buildpack's template, the processes generated from the Procfile and the
+// Supervise components, and the app's own process-compose.yaml. An app
+// process replaces the generated process of the same name as a whole, and
+// app top-level settings replace the template's. Only generated processes
+// get the bindings and env files of runtime.process_env and the app's .env
+// files.
+func writeProcessComposeConfig(templatePath, destPath string, options processComposeOptions) (processConfig, error) {
 	config, err := loadProcessComposeTemplate(templatePath)
 	if err != nil {
-		return fmt.Errorf("failed to load process-compose template: %w", err)
+		return processConfig{}, fmt.Errorf("failed to load process-compose template: %w", err)
 	}
 
 	processes := config.Processes
@@ -186,47 +638,178 @@ func writeProcessComposeConfig(templatePath, destPath, devCommand, agentCommand,
 		processes = map[string]processEntry{}
 	}
 
-	if devCommand != "" {
-		processes["dev"] = processEntry{
-			Description: "Development process from Procfile",
-			Command:     devCommand,
+	if procfileCommand(options.Procfile, defaultProcessType) == "" {
+		delete(processes, defaultProcessType)
+	}
+
+	// generated collects the processes the runtime adds, which replace
+	// template processes of the same name.
+	generated := map[string]processEntry{}
+
+	for _, process := range options.Procfile {
+		description := fmt.Sprintf("%s process from %s", process.Type, process.Source)
+		if process.Type == defaultProcessType {
+			description = fmt.Sprintf("Development process from %s", process.Source)
 		}
-	} else {
-		delete(processes, "dev")
+
+		entry := processEntry{
+			Description: description,
+			Command:     process.Command,
+		}
+
+		// The dev server is ready once it accepts connections on $PORT, which
+		// process-compose expands from the launch environment.
+		if process.Type == defaultProcessType && options.DevProbe {
+			entry.ReadinessProbe = &probeConfig{
+				Exec:             &execProbe{Command: `bash -c 'exec 3<>/dev/tcp/127.0.0.1/$PORT'`},
+				PeriodSeconds:    2,
+				TimeoutSeconds:   1,
+				FailureThreshold: 150,
+			}
+		}
+
+		generated[process.Type] = entry
 	}
 
-	processes["agent"] = processEntry{
-		Description: "Supervise agent",
-		Command:     agentCommand,
+	// postStartCommand runs once per start, like in a dev container.
+	if options.PostStart.Command != "" {
+		generated[options.PostStart.Type] = processEntry{
+			Description:  "postStartCommand from " + options.PostStart.Source,
+			Command:      options.PostStart.Command,
+			Availability: &availabilityConfig{Restart: "no"},
+		}
 	}
 
-	if _, err := os.Stat(caddyConfigPath); err == nil {
-		processes["caddy"] = processEntry{
-			Description: "Caddy reverse proxy",
-			Command:     fmt.Sprintf("%s run --config %s --adapter caddyfile", defaultCaddyBinaryPath, caddyConfigPath),
+	// An empty AgentCommand or CaddyConfigPath means the component was
+	// disabled through BP_SUPERVISE_DISABLE.
+	if options.AgentCommand != "" {
+		// The tmux server runs as its own process so the agent session
+		// outlives ttyd: when ttyd restarts it reattaches to the session
+		// instead of starting a new one.
+		generated["tmux"] = processEntry{
+			Description: "tmux server for terminal sessions",
+			Command:     fmt.Sprintf("pkgx +tmux -- tmux -S %s -D", tmuxSocketPath),
+			ReadinessProbe: &probeConfig{
+				Exec:             &execProbe{Command: "test -S " + tmuxSocketPath},
+				PeriodSeconds:    1,
+				TimeoutSeconds:   1,
+				FailureThreshold: 60,
+			},
+		}
+
+		generated["agent"] = processEntry{
+			Description: "Supervise agent",
+			Command: fmt.Sprintf("%s -tmux-socket %s -sessions %s -ttyd-config %s",
+				options.AgentCommand, tmuxSocketPath, options.SessionsPath, options.TTYDConfigPath),
+			DependsOn: map[string]dependencyConfig{
+				"tmux": {Condition: "process_healthy"},
+			},
+			// Room for the last recording upload after ttyd has exited,
+			// which the agent bounds to 20 seconds.
+			Shutdown: &shutdownConfig{TimeoutSeconds: 30},
+			// ttyd is ready to be proxied once it has created its socket.
+			ReadinessProbe: &probeConfig{
+				Exec:             &execProbe{Command: "test -S " + ttydSocketPath},
+				PeriodSeconds:    1,
+				TimeoutSeconds:   1,
+				FailureThreshold: 120,
+			},
+		}
+
+		// The watch terminal is a second, read-only ttyd on the same tmux
+		// server, so observers can follow a session without typing into it.
+		// Caddy refuses /watch when ENABLE_JWT_AUTH is true.
+		generated["watch"] = processEntry{
+			Description: "Read-only terminal for observers",
+			Command: fmt.Sprintf("%s -read-only -socket %s -tmux-socket %s -sessions %s -ttyd-config %s",
+				options.AgentCommand, watchSocketPath, tmuxSocketPath, options.SessionsPath, options.TTYDConfigPath),
 			DependsOn: map[string]dependencyConfig{
-				"agent": {Condition: "process_started"},
+				"agent": {Condition: "process_healthy"},
+			},
+			ReadinessProbe: &probeConfig{
+				Exec:             &execProbe{Command: "test -S " + watchSocketPath},
+				PeriodSeconds:    1,
+				TimeoutSeconds:   1,
+				FailureThreshold: 120,
 			},
+		}
+	} else {
+		delete(processes, "tmux")
+		delete(processes, "agent")
+		delete(processes, "watch")
+	}
+
+	if _, err := os.Stat(options.CaddyConfigPath); options.CaddyConfigPath != "" && err == nil {
+		caddy := processEntry{
+			Description: "Caddy reverse proxy",
+			Command:     fmt.Sprintf("%s run --config %s --adapter caddyfile", defaultCaddyBinaryPath, options.CaddyConfigPath),
 			Environment: []string{
 				"XDG_CONFIG_HOME=/tmp", // Use writable directory for Caddy config autosave
 			},
 		}
+
+		// Only the main terminal gates caddy: /watch is optional, and until
+		// the read-only terminal is up its requests fail on their own.
+		if options.AgentCommand != "" {
+			caddy.DependsOn = map[string]dependencyConfig{
+				"agent": {Condition: "process_healthy"},
+			}
+		}
+
+		generated["caddy"] = caddy
 	} else {
 		delete(processes, "caddy")
 	}
 
+	for name := range options.ProcessEnv {
+		if _, ok := generated[name]; !ok {
+			return processConfig{}, fmt.Errorf("runtime.process_env: no generated process %q", name)
+		}
+	}
+
+	claimed := claimedBindings(options.ProcessEnv)
+	for name, process := range generated {
+		if process.Availability == nil {
+			process.Availability = options.Restarts.forProcess(name)
+		}
+
+		if options.EnvScriptPath != "" {
+			process = withProcessEnv(process, name, options.EnvScriptPath, options.ProcessEnv[name], claimed, options.Dotenv)
+		}
+
+		processes[name] = process
+	}
+
+	for name, process := range options.AppConfig.Processes {
+		processes[name] = process
+	}
+
+	if config.Extra == nil {
+		config.Extra = map[string]interface{}{}
+	}
+
+	for key, value := range options.AppConfig.Extra {
+		config.Extra[key] = value
+	}
+
+	// process-compose logs each exit code and restart at info level, which is
+	// what explains a crash loop in PC_LOG_FILE.
+	if _, ok := config.Extra["log_level"]; !ok {
+		config.Extra["log_level"] = "info"
+	}
+
 	config.Processes = processes
 
 	data, err := yaml.Marshal(config)
 	if err != nil {
-		return fmt.Errorf("failed to marshal process-compose config: %w", err)
+		return processConfig{}, fmt.Errorf("failed to marshal process-compose config: %w", err)
 	}
 
 	if err := os.WriteFile(destPath, data, 0o644); err != nil {
-		return fmt.Errorf("failed to write process-compose.yaml: %w", err)
+		return processConfig{}, fmt.Errorf("failed to write process-compose.yaml: %w", err)
 	}
 
-	return nil
+	return config, nil
 }
 
 func loadProcessComposeTemplate(path string) (processConfig, error) {
@@ -245,3 +828,46 @@ func loadProcessComposeTemplate(path string) (processConfig, error) {
 
 	return config, nil
 }
+
+// appProcessComposePaths are the locations, relative to the app directory,
+// where an app can supply its own process-compose config. The first one found
+// is used, unless runtime.process_compose names the file.
+var appProcessComposePaths = []string{
+	filepath.Join(".supervise", "process-compose.yaml"),
+	"process-compose.yaml",
+}
+
+func loadAppProcessCompose(workingDir, configured string) (processConfig, string, error) {
+	if configured != "" {
+		fullPath := filepath.Join(workingDir, configured)
+		if _, err := os.Stat(fullPath); err != nil {
+			return processConfig{}, "", fmt.Errorf("runtime.process_compose: %w", err)
+		}
+
+		config, err := loadProcessComposeTemplate(fullPath)
+		if err != nil {
+			return processConfig{}, "", fmt.Errorf("failed to load %s: %w", configured, err)
+		}
+
+		return config, configured, nil
+	}
+
+	for _, path := range appProcessComposePaths {
+		fullPath := filepath.Join(workingDir, path)
+		if _, err := os.Stat(fullPath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return processConfig{}, "", fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		config, err := loadProcessComposeTemplate(fullPath)
+		if err != nil {
+			return processConfig{}, "", fmt.Errorf("failed to load %s: %w", path, err)
+		}
+
+		return config, path, nil
+	}
+
+	return processConfig{}, "", nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteProcessComposeConfig(t *testing.T) {
+	const template = `
+environment:
+  - FROM_TEMPLATE=1
+log_location: /tmp/template.log
+processes:
+  dev:
+    command: template-dev
+  docs:
+    command: template-docs
+    availability:
+      restart: always
+`
+
+	restarts := restartPolicies{
+		Default: availabilityConfig{Restart: "on_failure", BackoffSeconds: 2, MaxRestarts: 5},
+		Overrides: map[string]availabilityConfig{
+			"worker": {Restart: "always", BackoffSeconds: 1},
+		},
+	}
+	procfile := []procfileProcess{
+		{Type: "dev", Command: "npm run dev", Source: "Procfile"},
+		{Type: "worker", Command: "node worker.js", Source: "Procfile"},
+	}
+	templateExtra := map[string]interface{}{
+		"environment":  []interface{}{"FROM_TEMPLATE=1"},
+		"log_location": "/tmp/template.log",
+		"log_level":    "info",
+	}
+	templateDocs := processEntry{Command: "template-docs", Availability: &availabilityConfig{Restart: "always"}}
+
+	tests := []struct {
+		name    string
+		options processComposeOptions
+		want    processConfig
+	}{
+		{
+			name:    "template without a Procfile dev entry",
+			options: processComposeOptions{Restarts: restarts},
+			want: processConfig{
+				Processes: map[string]processEntry{"docs": templateDocs},
+				Extra:     templateExtra,
+			},
+		},
+		{
+			name:    "generated processes replace template ones",
+			options: processComposeOptions{Procfile: procfile, Restarts: restarts},
+			want: processConfig{
+				Processes: map[string]processEntry{
+					"docs": templateDocs,
+					"dev": {
+						Description:  "Development process from Procfile",
+						Command:      "npm run dev",
+						Availability: &availabilityConfig{Restart: "on_failure", BackoffSeconds: 2, MaxRestarts: 5},
+					},
+					"worker": {
+						Description:  "worker process from Procfile",
+						Command:      "node worker.js",
+						Availability: &availabilityConfig{Restart: "always", BackoffSeconds: 1},
+					},
+				},
+				Extra: templateExtra,
+			},
+		},
+		{
+			name: "env wrapping applies to generated processes only",
+			options: processComposeOptions{
+				Procfile:      procfile[:1],
+				Restarts:      restarts,
+				EnvScriptPath: "/layers/env.sh",
+				ProcessEnv: map[string]supervise.ProcessEnvSettings{
+					"dev": {Environment: map[string]string{"MODE": "dev"}},
+				},
+				AppConfig: processConfig{
+					Processes: map[string]processEntry{"app": {Command: "app-only"}},
+				},
+			},
+			want: processConfig{
+				Processes: map[string]processEntry{
+					"docs": templateDocs,
+					"dev": {
+						Description:  "Development process from Procfile",
+						Command:      "set -- dev && . /layers/env.sh && {\nnpm run dev\n}",
+						Environment:  []string{"MODE=dev"},
+						Availability: &availabilityConfig{Restart: "on_failure", BackoffSeconds: 2, MaxRestarts: 5},
+					},
+					"app": {Command: "app-only"},
+				},
+				Extra: templateExtra,
+			},
+		},
+		{
+			name: "app processes replace generated ones whole",
+			options: processComposeOptions{
+				Procfile:      procfile,
+				Restarts:      restarts,
+				EnvScriptPath: "/layers/env.sh",
+				AppConfig: processConfig{
+					Processes: map[string]processEntry{
+						"dev":  {Command: "app-dev"},
+						"docs": {Command: "app-docs"},
+					},
+				},
+			},
+			want: processConfig{
+				Processes: map[string]processEntry{
+					"docs": {Command: "app-docs"},
+					"dev":  {Command: "app-dev"},
+					"worker": {
+						Description:  "worker process from Procfile",
+						Command:      "set -- worker && . /layers/env.sh && {\nnode worker.js\n}",
+						Availability: &availabilityConfig{Restart: "always", BackoffSeconds: 1},
+					},
+				},
+				Extra: templateExtra,
+			},
+		},
+		{
+			name: "app top-level settings merge over the template",
+			options: processComposeOptions{
+				Restarts: restarts,
+				AppConfig: processConfig{
+					Extra: map[string]interface{}{
+						"log_location": "/tmp/app.log",
+						"log_level":    "debug",
+					},
+				},
+			},
+			want: processConfig{
+				Processes: map[string]processEntry{"docs": templateDocs},
+				Extra: map[string]interface{}{
+					"environment":  []interface{}{"FROM_TEMPLATE=1"},
+					"log_location": "/tmp/app.log",
+					"log_level":    "debug",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			templatePath := filepath.Join(dir, "template.yaml")
+			if err := os.WriteFile(templatePath, []byte(template), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			destPath := filepath.Join(dir, "process-compose.yaml")
+
+			if _, err := writeProcessComposeConfig(templatePath, destPath, tt.options); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got processConfig
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("writeProcessComposeConfig wrote:\n%s\nwant %+v", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteProcessComposeConfigRejectsTemplateProcessEnv(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.yaml")
+	if err := os.WriteFile(templatePath, []byte("processes:\n  docs:\n    command: template-docs\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := writeProcessComposeConfig(templatePath, filepath.Join(dir, "process-compose.yaml"), processComposeOptions{
+		ProcessEnv: map[string]supervise.ProcessEnvSettings{"docs": {}},
+	})
+	if err == nil || !strings.Contains(err.Error(), `no generated process "docs"`) {
+		t.Errorf("writeProcessComposeConfig error = %v, want one naming docs", err)
+	}
+}
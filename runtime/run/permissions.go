@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+const (
+	writablePathsEnv    = "BP_SUPERVISE_WRITABLE_PATHS"
+	writableModeEnv     = "BP_SUPERVISE_WRITABLE_MODE"
+	defaultWritableMode = fs.FileMode(0o222)
+)
+
+// chmod is replaced in tests to simulate read-only mounts.
+var chmod = os.Chmod
+
+// writableReport summarises what makeWritable did, so the build output shows
+// exactly which permission changes ended up in the image.
+type writableReport struct {
+	Changed   int
+	Unchanged int
+	Symlinks  int
+	ReadOnly  int
+	Missing   []string
+}
+
+// writableConfig returns the paths (relative to the app directory) and the
+// permission bits to add to them, from runtime.writable_paths and
+// runtime.writable_mode or BP_SUPERVISE_WRITABLE_PATHS and
+// BP_SUPERVISE_WRITABLE_MODE. No paths are made writable unless configured:
+// the launch user owns the app files, so only directories written by another
+// user (a cache or upload directory, say) need listing. A mode of 0 disables
+// permission fixing.
+func writableConfig(settings supervise.RuntimeSettings) ([]string, fs.FileMode, error) {
+	var paths []string
+	for _, path := range settings.WritablePaths {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	value := strings.TrimSpace(settings.WritableMode)
+	parsed, err := strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8, 32)
+	if err != nil || parsed > 0o777 {
+		return nil, 0, fmt.Errorf("runtime.writable_mode (%s): invalid permission bits %q, expected an octal mode such as 0222", writableModeEnv, value)
+	}
+
+	return paths, fs.FileMode(parsed), nil
+}
+
+// makeWritable adds mode to the permissions of every file and directory under
+// the given paths of root. Symlinks are never followed, so nothing outside the
+// app directory is touched, and read-only mounts are skipped rather than
+// failing the build.
+func makeWritable(root string, paths []string, mode fs.FileMode) (writableReport, error) {
+	var report writableReport
+	if mode == 0 {
+		return report, nil
+	}
+
+	for _, path := range paths {
+		target := filepath.Join(root, path)
+		if err := ensureWithinDir(root, target); err != nil {
+			return report, fmt.Errorf("writable paths: %w", err)
+		}
+
+		err := filepath.WalkDir(target, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && path == target {
+					report.Missing = append(report.Missing, path)
+					return nil
+				}
+				return err
+			}
+
+			if entry.Type()&fs.ModeSymlink != 0 {
+				report.Symlinks++
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			if info.Mode().Perm()&mode == mode {
+				report.Unchanged++
+				return nil
+			}
+
+			if err := chmod(path, info.Mode()|mode); err != nil {
+				if errors.Is(err, syscall.EROFS) {
+					report.ReadOnly++
+					if entry.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				return fmt.Errorf("failed to make %s writable: %w", path, err)
+			}
+
+			report.Changed++
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func ensureWithinDir(root, target string) error {
+	root = filepath.Clean(root)
+	target = filepath.Clean(target)
+
+	if !strings.HasPrefix(target, root+string(os.PathSeparator)) && target != root {
+		return fmt.Errorf("path escapes app directory: %s", target)
+	}
+
+	return nil
+}
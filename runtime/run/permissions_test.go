@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+func TestMakeWritable(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, root, outside string)
+		paths    []string
+		readOnly string // relative to root; chmod fails with EROFS below it
+		want     writableReport
+		wantErr  bool
+		check    func(t *testing.T, root, outside string)
+	}{
+		{
+			name: "adds the mode to files and directories",
+			setup: func(t *testing.T, root, outside string) {
+				mkdir(t, filepath.Join(root, "tmp"), 0o755)
+				writeFile(t, filepath.Join(root, "tmp", "cache"), 0o644)
+			},
+			paths: []string{"tmp"},
+			want:  writableReport{Changed: 2},
+			check: func(t *testing.T, root, outside string) {
+				assertMode(t, filepath.Join(root, "tmp"), 0o777)
+				assertMode(t, filepath.Join(root, "tmp", "cache"), 0o666)
+			},
+		},
+		{
+			name: "does not follow a symlink pointing outside the root",
+			setup: func(t *testing.T, root, outside string) {
+				writeFile(t, filepath.Join(outside, "secret"), 0o600)
+				mkdir(t, filepath.Join(root, "tmp"), 0o777)
+				symlink(t, filepath.Join(outside, "secret"), filepath.Join(root, "tmp", "secret"))
+				symlink(t, outside, filepath.Join(root, "linked"))
+			},
+			paths: []string{"tmp", "linked"},
+			want:  writableReport{Unchanged: 1, Symlinks: 2},
+			check: func(t *testing.T, root, outside string) {
+				assertMode(t, outside, 0o755)
+				assertMode(t, filepath.Join(outside, "secret"), 0o600)
+			},
+		},
+		{
+			name: "skips a read-only mount",
+			setup: func(t *testing.T, root, outside string) {
+				mkdir(t, filepath.Join(root, "data", "mounted"), 0o755)
+				writeFile(t, filepath.Join(root, "data", "mounted", "file"), 0o644)
+				writeFile(t, filepath.Join(root, "data", "local"), 0o644)
+			},
+			paths:    []string{"data"},
+			readOnly: filepath.Join("data", "mounted"),
+			want:     writableReport{Changed: 2, ReadOnly: 1},
+			check: func(t *testing.T, root, outside string) {
+				assertMode(t, filepath.Join(root, "data", "mounted", "file"), 0o644)
+				assertMode(t, filepath.Join(root, "data", "local"), 0o666)
+			},
+		},
+		{
+			name:  "reports a missing path",
+			paths: []string{"missing"},
+			want:  writableReport{Missing: []string{"missing"}},
+		},
+		{
+			name:    "rejects a path escaping the root",
+			paths:   []string{filepath.Join("..", "outside")},
+			wantErr: true,
+			check: func(t *testing.T, root, outside string) {
+				assertMode(t, outside, 0o755)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			root := filepath.Join(parent, "app")
+			outside := filepath.Join(parent, "outside")
+			mkdir(t, root, 0o755)
+			mkdir(t, outside, 0o755)
+			if tt.setup != nil {
+				tt.setup(t, root, outside)
+			}
+
+			if tt.readOnly != "" {
+				readOnly := filepath.Join(root, tt.readOnly)
+				chmod = func(path string, mode fs.FileMode) error {
+					if path == readOnly || filepath.Dir(path) == readOnly {
+						return &fs.PathError{Op: "chmod", Path: path, Err: syscall.EROFS}
+					}
+					return os.Chmod(path, mode)
+				}
+				t.Cleanup(func() { chmod = os.Chmod })
+			}
+
+			report, err := makeWritable(root, tt.paths, 0o222)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for i, path := range report.Missing {
+				report.Missing[i], _ = filepath.Rel(root, path)
+			}
+
+			if !tt.wantErr && !equalReports(report, tt.want) {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+
+			if tt.check != nil {
+				tt.check(t, root, outside)
+			}
+		})
+	}
+}
+
+func TestMakeWritableZeroMode(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "file"), 0o644)
+
+	report, err := makeWritable(root, []string{"."}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !equalReports(report, writableReport{}) {
+		t.Errorf("report = %+v, want nothing done", report)
+	}
+	assertMode(t, filepath.Join(root, "file"), 0o644)
+}
+
+func TestWritableConfig(t *testing.T) {
+	paths, mode, err := writableConfig(supervise.RuntimeSettings{WritablePaths: []string{" tmp ", ""}, WritableMode: "0o220"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(paths, []string{"tmp"}) || mode != 0o220 {
+		t.Errorf("writableConfig = %v, %#o, want [tmp], 0220", paths, mode)
+	}
+
+	for _, value := range []string{"0999", "01000", "rw"} {
+		_, _, err := writableConfig(supervise.RuntimeSettings{WritableMode: value})
+		if err == nil || !strings.Contains(err.Error(), "runtime.writable_mode ("+writableModeEnv+")") {
+			t.Errorf("writableConfig(%q) error = %v, want one naming runtime.writable_mode", value, err)
+		}
+	}
+}
+
+func equalReports(a, b writableReport) bool {
+	return a.Changed == b.Changed && a.Unchanged == b.Unchanged && a.Symlinks == b.Symlinks &&
+		a.ReadOnly == b.ReadOnly && slices.Equal(a.Missing, b.Missing)
+}
+
+func mkdir(t *testing.T, path string, mode fs.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path string, mode fs.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("content"), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertMode(t *testing.T, path string, want fs.FileMode) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("%s does not exist", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s mode = %#o, want %#o", path, got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultProcessType = "dev"
+
+	// agentProcessType is not a process of its own: a Procfile agent entry
+	// replaces the command the agent process runs inside the terminal.
+	agentProcessType = "agent"
+)
+
+// reservedProcessTypes are process-compose entries generated by the runtime
+// itself; Procfile entries may not reuse their names.
+var reservedProcessTypes = []string{"caddy", "tmux", "watch", postStartProcessType}
+
+var procfileLine = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*:\s*(.+?)\s*$`)
+
+type procfileProcess struct {
+	Type    string
+	Command string
+	Source  string // where the process was declared, e.g. "Procfile"
+}
+
+// readProcfile parses every entry of the app's Procfile, in file order.
+func readProcfile(workingDir string) ([]procfileProcess, error) {
+	return parseProcfile(filepath.Join(workingDir, "Procfile"), "Procfile", reservedProcessTypes)
+}
+
+// parseProcfile reads a file in Procfile format. Blank lines and lines
+// starting with # are ignored; a later entry with the same type replaces an
+// earlier one. A missing file has no entries.
+func parseProcfile(path, source string, reserved []string) ([]procfileProcess, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	defer file.Close()
+
+	var processes []procfileProcess
+	index := map[string]int{}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("%s line %d: expected \"<type>: <command>\", got %q", source, lineNumber, line)
+		}
+
+		process := procfileProcess{Type: match[1], Command: match[2], Source: source}
+		if slices.Contains(reserved, process.Type) {
+			return nil, fmt.Errorf("%s line %d: process type %q is reserved by the runtime", source, lineNumber, process.Type)
+		}
+
+		if i, ok := index[process.Type]; ok {
+			processes[i] = process
+			continue
+		}
+
+		index[process.Type] = len(processes)
+		processes = append(processes, process)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", source, err)
+	}
+
+	return processes, nil
+}
+
+// procfileCommand returns the command of the given process type, or an empty
+// string when the Procfile does not declare it.
+func procfileCommand(processes []procfileProcess, processType string) string {
+	for _, process := range processes {
+		if process.Type == processType {
+			return process.Command
+		}
+	}
+
+	return ""
+}
+
+// withoutProcess returns processes minus the entry of the given type.
+func withoutProcess(processes []procfileProcess, processType string) []procfileProcess {
+	var filtered []procfileProcess
+	for _, process := range processes {
+		if process.Type != processType {
+			filtered = append(filtered, process)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+func TestParseProcfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []procfileProcess
+		wantErr string
+	}{
+		{
+			name:    "entries in file order",
+			content: "# processes\n\ndev: npm run dev\nworker:  node worker.js  \n",
+			want: []procfileProcess{
+				{Type: "dev", Command: "npm run dev", Source: "Procfile"},
+				{Type: "worker", Command: "node worker.js", Source: "Procfile"},
+			},
+		},
+		{
+			name:    "later duplicate replaces the earlier entry in place",
+			content: "dev: npm run dev\nworker: node worker.js\ndev: npm start\n",
+			want: []procfileProcess{
+				{Type: "dev", Command: "npm start", Source: "Procfile"},
+				{Type: "worker", Command: "node worker.js", Source: "Procfile"},
+			},
+		},
+		{
+			name:    "agent entry is parsed like any other",
+			content: "agent: claude --continue\n",
+			want:    []procfileProcess{{Type: "agent", Command: "claude --continue", Source: "Procfile"}},
+		},
+		{
+			name:    "reserved name",
+			content: "dev: npm run dev\ncaddy: caddy run\n",
+			wantErr: `Procfile line 2: process type "caddy" is reserved by the runtime`,
+		},
+		{
+			name:    "post-start is reserved",
+			content: "post-start: make seed\n",
+			wantErr: `process type "post-start" is reserved by the runtime`,
+		},
+		{
+			name:    "malformed line",
+			content: "dev npm run dev\n",
+			wantErr: `Procfile line 1: expected "<type>: <command>"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "Procfile"), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			processes, err := readProcfile(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("readProcfile error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(processes, tt.want) {
+				t.Errorf("readProcfile = %+v, want %+v", processes, tt.want)
+			}
+		})
+	}
+
+	processes, err := readProcfile(t.TempDir())
+	if err != nil || processes != nil {
+		t.Errorf("readProcfile without a Procfile = %+v, %v, want no entries", processes, err)
+	}
+}
+
+func TestProcfileAgentEntry(t *testing.T) {
+	procfile := []procfileProcess{
+		{Type: "dev", Command: "npm run dev", Source: "Procfile"},
+		{Type: agentProcessType, Command: "claude --continue", Source: "Procfile"},
+	}
+
+	if command := procfileCommand(procfile, agentProcessType); command != "claude --continue" {
+		t.Errorf("procfileCommand(agent) = %q, want the Procfile entry", command)
+	}
+
+	want := []procfileProcess{{Type: "dev", Command: "npm run dev", Source: "Procfile"}}
+	if got := withoutProcess(procfile, agentProcessType); !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutProcess(agent) = %+v, want %+v", got, want)
+	}
+
+	if command := procfileCommand(want, agentProcessType); command != "" {
+		t.Errorf("procfileCommand(agent) = %q without an agent entry, want none", command)
+	}
+}
+
+func TestLaunchProcesses(t *testing.T) {
+	const path = "/layers/runtime/process-compose.yaml"
+	base := []string{"process-compose@" + processComposeVersion}
+	flags := []string{"--tui=false", "--disable-dotenv", "-f", path}
+
+	args := func(prefix []string, names ...string) []string {
+		args := append([]string{}, prefix...)
+		args = append(args, base...)
+		if len(names) > 0 {
+			args = append(args, "up")
+		}
+		args = append(args, flags...)
+		return append(args, names...)
+	}
+
+	tests := []struct {
+		name     string
+		procfile []procfileProcess
+		shared   []string
+		packages []string
+		want     []packit.DirectProcess
+	}{
+		{
+			name: "no Procfile starts every process",
+			want: []packit.DirectProcess{
+				{Type: "dev", Command: []string{"pkgx"}, Args: args(nil), Default: true},
+			},
+		},
+		{
+			name: "each entry starts itself and the shared processes",
+			procfile: []procfileProcess{
+				{Type: "worker", Command: "node worker.js"},
+				{Type: "dev", Command: "npm run dev"},
+			},
+			shared:   []string{"agent", "caddy"},
+			packages: []string{"nodejs.org@22", "python.org"},
+			want: []packit.DirectProcess{
+				{
+					Type:    "dev",
+					Command: []string{"pkgx"},
+					Args:    args([]string{"+nodejs.org@22", "+python.org"}, "dev", "agent", "caddy"),
+					Default: true,
+				},
+				{
+					Type:    "worker",
+					Command: []string{"pkgx"},
+					Args:    args([]string{"+nodejs.org@22", "+python.org"}, "worker", "agent", "caddy"),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processes := launchProcesses(path, tt.procfile, tt.shared, tt.packages)
+			if !reflect.DeepEqual(processes, tt.want) {
+				t.Errorf("launchProcesses =\n%+v\nwant\n%+v", processes, tt.want)
+			}
+		})
+	}
+}
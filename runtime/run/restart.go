@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	restartEnv = "BP_SUPERVISE_RESTART"
+
+	defaultRestartPolicy  = "on_failure"
+	defaultBackoffSeconds = 2
+	defaultMaxRestarts    = 5
+)
+
+var restartPolicyNames = []string{"always", "on_failure", "exit_on_failure", "no"}
+
+// restartPolicies holds the availability settings for generated processes.
+// runtime.restart or BP_SUPERVISE_RESTART replaces the default for all of
+// them and BP_SUPERVISE_RESTART_<PROCESS> (e.g. BP_SUPERVISE_RESTART_AGENT)
+// replaces it for one. Both take
+// "<policy>[:<backoff seconds>[:<max restarts>]]", such as "always" or
+// "on_failure:5:10"; omitted parts keep the default.
+type restartPolicies struct {
+	Default   availabilityConfig
+	Overrides map[string]availabilityConfig
+}
+
+func readRestartPolicies(defaultPolicy string) (restartPolicies, error) {
+	policies := restartPolicies{
+		Default: availabilityConfig{
+			Restart:        defaultRestartPolicy,
+			BackoffSeconds: defaultBackoffSeconds,
+			MaxRestarts:    defaultMaxRestarts,
+		},
+		Overrides: map[string]availabilityConfig{},
+	}
+
+	if defaultPolicy != "" {
+		availability, err := parseRestartPolicy("runtime.restart", defaultPolicy, policies.Default)
+		if err != nil {
+			return restartPolicies{}, err
+		}
+		policies.Default = availability
+	}
+
+	for _, variable := range os.Environ() {
+		key, value, _ := strings.Cut(variable, "=")
+		name, ok := strings.CutPrefix(key, restartEnv+"_")
+		if !ok || name == "" {
+			continue
+		}
+
+		availability, err := parseRestartPolicy(key, value, policies.Default)
+		if err != nil {
+			return restartPolicies{}, err
+		}
+		policies.Overrides[strings.ToLower(name)] = availability
+	}
+
+	return policies, nil
+}
+
+func parseRestartPolicy(key, value string, defaults availabilityConfig) (availabilityConfig, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return availabilityConfig{}, fmt.Errorf("%s: expected <policy>[:<backoff seconds>[:<max restarts>]], got %q", key, value)
+	}
+
+	availability := defaults
+	if parts[0] != "" {
+		availability.Restart = parts[0]
+	}
+
+	if !slices.Contains(restartPolicyNames, availability.Restart) {
+		return availabilityConfig{}, fmt.Errorf("%s: unknown restart policy %q, expected one of %s", key, availability.Restart, strings.Join(restartPolicyNames, ", "))
+	}
+
+	for i, target := range []*int{&availability.BackoffSeconds, &availability.MaxRestarts} {
+		if len(parts) <= i+1 || parts[i+1] == "" {
+			continue
+		}
+
+		number, err := strconv.Atoi(parts[i+1])
+		if err != nil || number < 0 {
+			return availabilityConfig{}, fmt.Errorf("%s: %q is not a non-negative number", key, parts[i+1])
+		}
+		*target = number
+	}
+
+	return availability, nil
+}
+
+// forProcess returns the availability settings for the named process.
+// Process names are matched case-insensitively with "-" read as "_", the
+// closest an environment variable name can get.
+func (p restartPolicies) forProcess(name string) *availabilityConfig {
+	availability := p.Default
+	if override, ok := p.Overrides[strings.ReplaceAll(strings.ToLower(name), "-", "_")]; ok {
+		availability = override
+	}
+
+	return &availability
+}
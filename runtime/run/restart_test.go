@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	defaults := availabilityConfig{Restart: defaultRestartPolicy, BackoffSeconds: defaultBackoffSeconds, MaxRestarts: defaultMaxRestarts}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    availabilityConfig
+		wantErr string
+	}{
+		{name: "policy", value: "always", want: availabilityConfig{Restart: "always", BackoffSeconds: 2, MaxRestarts: 5}},
+		{name: "policy and backoff", value: "no:10", want: availabilityConfig{Restart: "no", BackoffSeconds: 10, MaxRestarts: 5}},
+		{name: "policy, backoff and max", value: " exit_on_failure:0:1 ", want: availabilityConfig{Restart: "exit_on_failure", BackoffSeconds: 0, MaxRestarts: 1}},
+		{name: "omitted parts keep the defaults", value: "::7", want: availabilityConfig{Restart: "on_failure", BackoffSeconds: 2, MaxRestarts: 7}},
+		{name: "too many parts", value: "always:1:2:3", wantErr: "expected <policy>[:<backoff seconds>[:<max restarts>]]"},
+		{name: "unknown policy", value: "sometimes", wantErr: `unknown restart policy "sometimes"`},
+		{name: "non-numeric backoff", value: "always:soon", wantErr: `"soon" is not a non-negative number`},
+		{name: "negative max", value: "always:1:-1", wantErr: `"-1" is not a non-negative number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			availability, err := parseRestartPolicy(restartEnv, tt.value, defaults)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), restartEnv+": ") {
+					t.Errorf("parseRestartPolicy(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if availability.Restart != tt.want.Restart || availability.BackoffSeconds != tt.want.BackoffSeconds || availability.MaxRestarts != tt.want.MaxRestarts {
+				t.Errorf("parseRestartPolicy(%q) = %+v, want %+v", tt.value, availability, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRestartPoliciesPerProcess(t *testing.T) {
+	t.Setenv(restartEnv+"_AGENT", "always")
+	t.Setenv(restartEnv+"_POST_START", ":30")
+
+	policies, err := readRestartPolicies("no:4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		process string
+		want    availabilityConfig
+	}{
+		{process: "dev", want: availabilityConfig{Restart: "no", BackoffSeconds: 4, MaxRestarts: 5}},
+		{process: "agent", want: availabilityConfig{Restart: "always", BackoffSeconds: 4, MaxRestarts: 5}},
+		{process: "post-start", want: availabilityConfig{Restart: "no", BackoffSeconds: 30, MaxRestarts: 5}},
+	}
+
+	for _, tt := range tests {
+		availability := policies.forProcess(tt.process)
+		if availability.Restart != tt.want.Restart || availability.BackoffSeconds != tt.want.BackoffSeconds || availability.MaxRestarts != tt.want.MaxRestarts {
+			t.Errorf("forProcess(%q) = %+v, want %+v", tt.process, *availability, tt.want)
+		}
+	}
+
+	t.Setenv(restartEnv+"_WORKER", "always:x")
+	if _, err := readRestartPolicies(""); err == nil || !strings.Contains(err.Error(), restartEnv+"_WORKER") {
+		t.Errorf("readRestartPolicies error = %v, want one naming %s_WORKER", err, restartEnv)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+)
+
+// runtimeSBOM builds CycloneDX, SPDX and Syft documents for the runtime layer.
+// process-compose is resolved through pkgx when the image starts, at the
+// pinned processComposeVersion; the agent launcher and generated config are
+// recorded by digest.
+func runtimeSBOM(info packit.BuildpackInfo, layerPath string, files ...string) (packit.SBOMFormats, error) {
+	subject := sbom.Component{
+		Name:    "supervise-runtime",
+		Version: info.Version,
+		PURL:    fmt.Sprintf("pkg:generic/%s@%s", info.ID, url.PathEscape(info.Version)),
+		Type:    "binary",
+	}
+
+	components := []sbom.Component{
+		{
+			Name:    "process-compose",
+			Version: processComposeVersion,
+			PURL:    fmt.Sprintf("pkg:github/f1bonacc1/process-compose@v%s", processComposeVersion),
+			Type:    "binary",
+		},
+	}
+
+	for _, path := range files {
+		checksum, err := sbom.FileSHA256(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", path, err)
+		}
+
+		location, err := filepath.Rel(layerPath, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve location of %s: %w", path, err)
+		}
+
+		name := filepath.Base(path)
+		components = append(components, sbom.Component{
+			Name:     name,
+			Version:  info.Version,
+			PURL:     fmt.Sprintf("pkg:generic/%s@%s?checksum=sha256:%s", url.PathEscape(name), url.PathEscape(info.Version), checksum),
+			SHA256:   checksum,
+			Type:     "binary",
+			Location: location,
+		})
+	}
+
+	return sbom.Formats(info, subject, components)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sessionsSource is where an app can list its default terminal sessions
+// instead of runtime.sessions, in Procfile format ("<name>: <command>"), e.g.
+//
+//	agent: pkgx aider
+//	shell: bash -l
+//	logs: tail -f /tmp/process-compose.log
+//
+// Each one is started next to the agent session and opened at
+// /terminal/<name>. Any other name under /terminal/ gets a fresh shell.
+var sessionsSource = filepath.Join(".supervise", "sessions")
+
+type terminalSession struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// sessionName is the session name syntax of the sessions file, which the
+// agent launcher also checks.
+var sessionName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// writeSessions converts runtime.sessions, sorted by name, or the app's
+// sessions file into the JSON list the agent launcher reads at launch.
+func writeSessions(workingDir, destPath string, configured map[string]string) ([]terminalSession, error) {
+	entries, err := parseProcfile(filepath.Join(workingDir, sessionsSource), sessionsSource, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]terminalSession, 0, len(entries))
+	for _, entry := range entries {
+		sessions = append(sessions, terminalSession{Name: entry.Type, Command: entry.Command})
+	}
+
+	if configured != nil {
+		if len(entries) > 0 {
+			return nil, fmt.Errorf("both runtime.sessions and %s list terminal sessions, remove one of them", sessionsSource)
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(configured)) {
+			command := strings.TrimSpace(configured[name])
+			if !sessionName.MatchString(name) || command == "" {
+				return nil, fmt.Errorf("runtime.sessions: expected <name> = \"<command>\" with a name of letters, digits, - and _, got %s = %q", name, configured[name])
+			}
+			sessions = append(sessions, terminalSession{Name: name, Command: command})
+		}
+	}
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal terminal sessions: %w", err)
+	}
+
+	if err := os.WriteFile(destPath, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write terminal sessions: %w", err)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWriteSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string // contents of .supervise/sessions, if any
+		configured map[string]string
+		want       []terminalSession
+		wantErr    bool
+	}{
+		{
+			name: "reads the sessions file",
+			file: "shell: bash -l\nlogs: tail -f /tmp/process-compose.log\n",
+			want: []terminalSession{
+				{Name: "shell", Command: "bash -l"},
+				{Name: "logs", Command: "tail -f /tmp/process-compose.log"},
+			},
+		},
+		{
+			name:       "sorts runtime.sessions by name",
+			configured: map[string]string{"shell": "bash -l", "logs": "tail -f /tmp/process-compose.log"},
+			want: []terminalSession{
+				{Name: "logs", Command: "tail -f /tmp/process-compose.log"},
+				{Name: "shell", Command: "bash -l"},
+			},
+		},
+		{
+			name:       "rejects an invalid session name",
+			configured: map[string]string{"my shell": "bash -l"},
+			wantErr:    true,
+		},
+		{
+			name:       "rejects an empty command",
+			configured: map[string]string{"shell": " "},
+			wantErr:    true,
+		},
+		{
+			name:       "rejects sessions from both sources",
+			file:       "shell: bash -l\n",
+			configured: map[string]string{"logs": "tail -f /tmp/process-compose.log"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			if tt.file != "" {
+				if err := os.MkdirAll(filepath.Join(workingDir, ".supervise"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(workingDir, sessionsSource), []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sessions, err := writeSessions(workingDir, filepath.Join(t.TempDir(), "sessions.json"), tt.configured)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("writeSessions = %v, want an error", sessions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(sessions, tt.want) {
+				t.Errorf("writeSessions = %v, want %v", sessions, tt.want)
+			}
+		})
+	}
+}
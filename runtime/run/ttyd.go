@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+	"gopkg.in/yaml.v3"
+)
+
+// ttydSource is where an app can tune its terminal instead of
+// runtime.terminal, e.g.
+//
+//	writable: false
+//	max_clients: 2
+//	ping_interval: 30
+//	client_options:
+//	  fontSize: 16
+//	  disableLeaveAlert: true
+//	  theme: {background: "#1e1e1e"}
+//
+// Unknown keys fail the build rather than being silently ignored.
+var ttydSource = filepath.Join(".supervise", "ttyd.yaml")
+
+type ttydOptions struct {
+	Writable      bool                   `yaml:"writable"`
+	MaxClients    int                    `yaml:"max_clients"`
+	Once          bool                   `yaml:"once"`
+	PingInterval  int                    `yaml:"ping_interval"`
+	ClientOptions map[string]interface{} `yaml:"client_options"`
+}
+
+// ttydLaunchConfig is what the agent launcher reads at launch to build the
+// ttyd command line. Client options are already rendered as the values of
+// ttyd's -t flag.
+type ttydLaunchConfig struct {
+	Writable      bool              `json:"writable"`
+	MaxClients    int               `json:"max_clients,omitempty"`
+	Once          bool              `json:"once,omitempty"`
+	PingInterval  int               `json:"ping_interval,omitempty"`
+	ClientOptions map[string]string `json:"client_options,omitempty"`
+}
+
+// writeTTYDConfig validates the app's ttyd settings, from runtime.terminal or
+// the settings file, and writes them for the agent launcher. Without either
+// the terminal is writable with ttyd's defaults for everything else.
+func writeTTYDConfig(workingDir, destPath string, terminal *supervise.TerminalSettings) (ttydLaunchConfig, error) {
+	options := ttydOptions{Writable: true}
+	source := ttydSource
+
+	data, err := os.ReadFile(filepath.Join(workingDir, ttydSource))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ttydLaunchConfig{}, fmt.Errorf("failed to read %s: %w", ttydSource, err)
+	}
+
+	if terminal != nil {
+		if data != nil {
+			return ttydLaunchConfig{}, fmt.Errorf("both runtime.terminal and %s configure the terminal, remove one of them", ttydSource)
+		}
+
+		source = "runtime.terminal"
+		options = ttydOptions{
+			Writable:      terminal.Writable == nil || *terminal.Writable,
+			MaxClients:    terminal.MaxClients,
+			Once:          terminal.Once,
+			PingInterval:  terminal.PingInterval,
+			ClientOptions: terminal.ClientOptions,
+		}
+	} else if len(data) > 0 {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&options); err != nil && !errors.Is(err, io.EOF) {
+			return ttydLaunchConfig{}, fmt.Errorf("failed to parse %s: %w", ttydSource, err)
+		}
+	}
+
+	if options.MaxClients < 0 || options.PingInterval < 0 {
+		return ttydLaunchConfig{}, fmt.Errorf("%s: max_clients and ping_interval must not be negative", source)
+	}
+
+	config := ttydLaunchConfig{
+		Writable:     options.Writable,
+		MaxClients:   options.MaxClients,
+		Once:         options.Once,
+		PingInterval: options.PingInterval,
+	}
+
+	keys := make([]string, 0, len(options.ClientOptions))
+	for key := range options.ClientOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, err := clientOptionValue(options.ClientOptions[key])
+		if err != nil {
+			return ttydLaunchConfig{}, fmt.Errorf("%s: client option %s: %w", source, key, err)
+		}
+
+		if config.ClientOptions == nil {
+			config.ClientOptions = map[string]string{}
+		}
+		config.ClientOptions[key] = value
+	}
+
+	encoded, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return ttydLaunchConfig{}, fmt.Errorf("failed to marshal ttyd config: %w", err)
+	}
+
+	if err := os.WriteFile(destPath, encoded, 0o644); err != nil {
+		return ttydLaunchConfig{}, fmt.Errorf("failed to write ttyd config: %w", err)
+	}
+
+	return config, nil
+}
+
+// clientOptionValue renders a value the way ttyd's -t key=value expects it:
+// strings as they are, everything else (numbers, booleans, the theme object)
+// as JSON.
+func clientOptionValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	encoded, err := json.Marshal(normalizeYAML(value))
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values yaml.v3 can
+// produce for nested mappings into something encoding/json accepts.
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
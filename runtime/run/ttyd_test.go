@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+func TestWriteTTYDConfigFromSettings(t *testing.T) {
+	writable := false
+	terminal := &supervise.TerminalSettings{
+		Writable:     &writable,
+		PingInterval: 30,
+		ClientOptions: map[string]interface{}{
+			"fontSize": int64(16),
+			"theme":    map[string]interface{}{"background": "#1e1e1e"},
+		},
+	}
+
+	config, err := writeTTYDConfig(t.TempDir(), filepath.Join(t.TempDir(), "ttyd.json"), terminal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ttydLaunchConfig{
+		PingInterval: 30,
+		ClientOptions: map[string]string{
+			"fontSize": "16",
+			"theme":    `{"background":"#1e1e1e"}`,
+		},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("writeTTYDConfig = %+v, want %+v", config, want)
+	}
+
+	config, err = writeTTYDConfig(t.TempDir(), filepath.Join(t.TempDir(), "ttyd.json"), &supervise.TerminalSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Writable {
+		t.Error("terminal is not writable when runtime.terminal leaves writable unset")
+	}
+}
+
+func TestWriteTTYDConfigRejectsBothSources(t *testing.T) {
+	workingDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workingDir, ".supervise"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workingDir, ttydSource), []byte("max_clients: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := writeTTYDConfig(workingDir, filepath.Join(t.TempDir(), "ttyd.json"), &supervise.TerminalSettings{})
+	if err == nil || !strings.Contains(err.Error(), "runtime.terminal") {
+		t.Errorf("writeTTYDConfig error = %v, want one naming runtime.terminal", err)
+	}
+}
+
+func TestWriteTTYDConfigNamesTheSource(t *testing.T) {
+	_, err := writeTTYDConfig(t.TempDir(), filepath.Join(t.TempDir(), "ttyd.json"), &supervise.TerminalSettings{MaxClients: -1})
+	if err == nil || !strings.HasPrefix(err.Error(), "runtime.terminal: ") {
+		t.Errorf("writeTTYDConfig error = %v, want one naming runtime.terminal", err)
+	}
+
+	workingDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workingDir, ".supervise"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workingDir, ttydSource), []byte("ping_interval: -5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = writeTTYDConfig(workingDir, filepath.Join(t.TempDir(), "ttyd.json"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), ttydSource+": ") {
+		t.Errorf("writeTTYDConfig error = %v, want one naming %s", err, ttydSource)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	upstreamProcessType = "web"
+
+	// groupFile is where the lifecycle records the buildpacks of the group,
+	// in order, in the layers directory.
+	groupFile = "group.toml"
+)
+
+// upstreamProcess is a launch process declared by another buildpack in the
+// group, e.g. the web process heroku/nodejs derives from package.json.
+type upstreamProcess struct {
+	Buildpack string
+	Type      string
+	Command   string
+}
+
+type launchProcess struct {
+	Type    string      `toml:"type"`
+	Command interface{} `toml:"command"`
+	Args    []string    `toml:"args"`
+}
+
+// readUpstreamProcess looks for a process of the given type in the
+// launch.toml files written by the buildpacks that built before this one. It
+// walks them in the order of the lifecycle's group.toml, next to the
+// buildpacks' layers directories, and like the lifecycle lets the last
+// buildpack that declares the type win. It returns false when none did, or
+// when there is no group.toml.
+func readUpstreamProcess(layersPath, processType string) (upstreamProcess, bool, error) {
+	root := filepath.Dir(layersPath)
+	groupPath := filepath.Join(root, groupFile)
+
+	var group struct {
+		Group []struct {
+			ID string `toml:"id"`
+		} `toml:"group"`
+	}
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return upstreamProcess{}, false, nil
+		}
+		return upstreamProcess{}, false, fmt.Errorf("failed to read %s: %w", groupPath, err)
+	}
+
+	var found upstreamProcess
+	for _, buildpack := range group.Group {
+		// Layers directories are named after the buildpack ID with "/"
+		// replaced by "_".
+		dir := filepath.Join(root, strings.ReplaceAll(buildpack.ID, "/", "_"))
+		if dir == filepath.Clean(layersPath) {
+			break
+		}
+
+		launchPath := filepath.Join(dir, "launch.toml")
+
+		var launch struct {
+			Processes []launchProcess `toml:"processes"`
+		}
+		if _, err := toml.DecodeFile(launchPath, &launch); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return upstreamProcess{}, false, fmt.Errorf("failed to read %s: %w", launchPath, err)
+		}
+
+		for _, process := range launch.Processes {
+			if process.Type != processType {
+				continue
+			}
+
+			command, err := shellCommand(process)
+			if err != nil {
+				return upstreamProcess{}, false, fmt.Errorf("%s: %w", launchPath, err)
+			}
+
+			found = upstreamProcess{
+				Buildpack: buildpack.ID,
+				Type:      process.Type,
+				Command:   command,
+			}
+		}
+	}
+
+	return found, found.Command != "", nil
+}
+
+// shellCommand turns a launch.toml process into a single command line for
+// process-compose, which runs commands through a shell. Before Buildpack API
+// 0.9 the command is a shell string; from 0.9 on it is an argv array.
+func shellCommand(process launchProcess) (string, error) {
+	var argv []string
+
+	switch command := process.Command.(type) {
+	case string:
+		argv = append(argv, command)
+		for _, arg := range process.Args {
+			argv = append(argv, shellQuote(arg))
+		}
+
+		return strings.Join(argv, " "), nil
+	case []interface{}:
+		for _, part := range command {
+			value, ok := part.(string)
+			if !ok {
+				return "", fmt.Errorf("process %q has a non-string command element", process.Type)
+			}
+			argv = append(argv, value)
+		}
+	default:
+		return "", fmt.Errorf("process %q has no command", process.Type)
+	}
+
+	argv = append(argv, process.Args...)
+	if len(argv) == 0 {
+		return "", fmt.Errorf("process %q has an empty command", process.Type)
+	}
+
+	quoted := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " "), nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLaunchTOML(t *testing.T, root, buildpack, content string) {
+	t.Helper()
+
+	dir := filepath.Join(root, buildpack)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "launch.toml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadUpstreamProcess(t *testing.T) {
+	root := t.TempDir()
+
+	// Group order differs from the alphabetical order of the directories,
+	// where heroku_nodejs comes first.
+	group := `
+[[group]]
+id = "heroku/nodejs"
+
+[[group]]
+id = "paketo-buildpacks/procfile"
+
+[[group]]
+id = "dev.supervise.runtime"
+
+[[group]]
+id = "later/buildpack"
+`
+	if err := os.WriteFile(filepath.Join(root, groupFile), []byte(group), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeLaunchTOML(t, root, "heroku_nodejs", `
+[[processes]]
+type = "web"
+command = ["npm", "start"]
+`)
+	writeLaunchTOML(t, root, "paketo-buildpacks_procfile", `
+[[processes]]
+type = "worker"
+command = ["node", "worker.js"]
+
+[[processes]]
+type = "web"
+command = ["node", "server.js"]
+args = ["--port", "8080"]
+`)
+	writeLaunchTOML(t, root, "later_buildpack", `
+[[processes]]
+type = "web"
+command = ["later"]
+`)
+	layersPath := filepath.Join(root, "dev.supervise.runtime")
+	if err := os.MkdirAll(layersPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	process, ok, err := readUpstreamProcess(layersPath, upstreamProcessType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last buildpack before the runtime wins, as in the lifecycle.
+	want := upstreamProcess{Buildpack: "paketo-buildpacks/procfile", Type: "web", Command: "node server.js --port 8080"}
+	if !ok || process != want {
+		t.Errorf("readUpstreamProcess = %+v, %t, want %+v", process, ok, want)
+	}
+
+	if _, ok, err := readUpstreamProcess(layersPath, "release"); err != nil || ok {
+		t.Errorf("readUpstreamProcess(release) = %t, %v, want no process", ok, err)
+	}
+}
+
+func TestReadUpstreamProcessWithoutGroup(t *testing.T) {
+	root := t.TempDir()
+	writeLaunchTOML(t, root, "heroku_nodejs", "[[processes]]\ntype = \"web\"\ncommand = [\"npm\", \"start\"]\n")
+
+	_, ok, err := readUpstreamProcess(filepath.Join(root, "dev.supervise.runtime"), upstreamProcessType)
+	if err != nil || ok {
+		t.Errorf("readUpstreamProcess = %t, %v, want no process without group.toml", ok, err)
+	}
+}
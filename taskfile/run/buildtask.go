@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	buildTaskEnv   = "BP_TASKFILE_BUILD_TASK"
+	stateLayerName = "task-state"
+)
+
+// runBuildTask runs the build task (taskfile.build_task or
+// BP_TASKFILE_BUILD_TASK) in the app directory. task's state directory
+// (normally .task, where it keeps the checksums of each task's sources) lives
+// in a cache layer, so a task whose sources and generated files are unchanged
+// is skipped on the next build.
+// It returns the cache layer, or false when no build task is configured.
+func runBuildTask(context packit.BuildContext, taskPath, name string) (packit.Layer, bool, error) {
+	if name == "" {
+		return packit.Layer{}, false, nil
+	}
+
+	layer, err := context.Layers.Get(stateLayerName)
+	if err != nil {
+		return packit.Layer{}, false, fmt.Errorf("failed to get %s layer: %w", stateLayerName, err)
+	}
+
+	if err := os.MkdirAll(layer.Path, 0o755); err != nil {
+		return packit.Layer{}, false, fmt.Errorf("failed to create %s layer: %w", stateLayerName, err)
+	}
+
+	fmt.Printf("Running task %s\n", name)
+
+	cmd := exec.Command(taskPath, name)
+	cmd.Dir = context.WorkingDir
+	cmd.Env = append(os.Environ(), "TASK_TEMP_DIR="+layer.Path)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return packit.Layer{}, false, fmt.Errorf("task %s failed (see its output above): %w", name, err)
+	}
+
+	layer.Cache = true
+	layer.Metadata = map[string]interface{}{
+		"task": name,
+	}
+
+	return layer, true, nil
+}
@@ -1 +1,311 @@
-package run
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+const (
+	layerName          = "task"
+	defaultTaskVersion = "v3.44.0"
+	versionEnv         = "BP_TASK_VERSION"
+	releasesBaseURL    = "https://github.com/go-task/task/releases/download"
+	checksumsAsset     = "task_checksums.txt"
+)
+
+// taskfileNames are the Taskfiles the buildpack detects, in the order task
+// itself looks for them.
+var taskfileNames = []string{"Taskfile.yml", "Taskfile.yaml"}
+
+var assetMap = map[string]string{
+	"linux/amd64": "task_linux_amd64.tar.gz",
+	"linux/arm64": "task_linux_arm64.tar.gz",
+}
+
+func main() {
+	packit.Run(detect, build)
+}
+
+func detect(context packit.DetectContext) (packit.DetectResult, error) {
+	taskfile, err := findTaskfile(context.WorkingDir)
+	if err != nil {
+		return packit.DetectResult{}, err
+	}
+
+	if taskfile == "" {
+		return packit.DetectResult{}, packit.Fail.WithMessage("no %s found", strings.Join(taskfileNames, " or "))
+	}
+
+	return packit.DetectResult{
+		Plan: packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: layerName},
+			},
+			Requires: []packit.BuildPlanRequirement{
+				{Name: layerName, Metadata: map[string]interface{}{"launch": true}},
+			},
+		},
+	}, nil
+}
+
+// findTaskfile returns the name of the app's Taskfile, or an empty string
+// when it has none.
+func findTaskfile(workingDir string) (string, error) {
+	for _, name := range taskfileNames {
+		_, err := os.Stat(filepath.Join(workingDir, name))
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+	}
+
+	return "", nil
+}
+
+func build(context packit.BuildContext) (packit.BuildResult, error) {
+	osName := runtime.GOOS
+	arch := runtime.GOARCH
+
+	assetKey := fmt.Sprintf("%s/%s", osName, arch)
+	assetName, ok := assetMap[assetKey]
+	if !ok {
+		return packit.BuildResult{}, fmt.Errorf("unsupported platform %s", assetKey)
+	}
+
+	config, source, err := supervise.LoadConfig(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	settings := config.Taskfile
+	if settings.Version == "" {
+		settings.Version = defaultTaskVersion
+	}
+	supervise.OverrideString(versionEnv, &settings.Version)
+	supervise.OverrideString(buildTaskEnv, &settings.BuildTask)
+	supervise.OverrideList(processesEnv, &settings.Processes)
+
+	supervise.PrintConfig(source, [][2]string{
+		{"taskfile.version", settings.Version},
+		{"taskfile.build_task", settings.BuildTask},
+		{"taskfile.processes", strings.Join(settings.Processes, ", ")},
+	})
+
+	version := settings.Version
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+
+	archiveURL := fmt.Sprintf("%s/%s/%s", releasesBaseURL, version, assetName)
+
+	layer, err := context.Layers.Get(layerName)
+	if err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to get %s layer: %w", layerName, err)
+	}
+
+	taskPath := filepath.Join(layer.Path, "bin", "task")
+
+	checksum, cached := layer.Metadata["checksum"].(string)
+	cached = cached &&
+		layer.Metadata["version"] == version &&
+		layer.Metadata["buildpack_version"] == context.BuildpackInfo.Version &&
+		fileExists(taskPath)
+
+	if cached {
+		fmt.Printf("Reusing task %s (sha256:%s)\n", version, checksum)
+	} else {
+		layer, err = layer.Reset()
+		if err != nil {
+			return packit.BuildResult{}, fmt.Errorf("failed to reset %s layer: %w", layerName, err)
+		}
+
+		checksum, err = installTask(version, assetName, archiveURL, taskPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		fmt.Printf("Installed task %s (sha256:%s)\n", version, checksum)
+	}
+
+	binarySHA256, err := sbom.FileSHA256(taskPath)
+	if err != nil {
+		return packit.BuildResult{}, fmt.Errorf("failed to hash task binary: %w", err)
+	}
+
+	layer.SBOM, err = taskSBOM(context.BuildpackInfo, version, assetName, checksum, binarySHA256, archiveURL)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	layer.Launch = true
+	layer.Build = true
+	layer.Cache = true
+
+	layer.Metadata = map[string]interface{}{
+		"checksum":          checksum,
+		"binary_checksum":   binarySHA256,
+		"uri":               archiveURL,
+		"version":           version,
+		"asset":             assetName,
+		"os":                osName,
+		"arch":              arch,
+		"buildpack_version": context.BuildpackInfo.Version,
+	}
+
+	layers := []packit.Layer{layer}
+
+	stateLayer, ok, err := runBuildTask(context, taskPath, settings.BuildTask)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+	if ok {
+		layers = append(layers, stateLayer)
+	}
+
+	processes, err := taskProcesses(taskPath, context.WorkingDir, settings.Processes)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
+	for _, process := range processes {
+		fmt.Printf("  Process %s: %s\n", process.Type, strings.Join(process.Command, " "))
+	}
+
+	return packit.BuildResult{
+		Layers: layers,
+		Launch: packit.LaunchMetadata{
+			DirectProcesses: processes,
+		},
+	}, nil
+}
+
+// installTask downloads the release archive, checks it against the
+// checksums published with the release and extracts the task binary to
+// taskPath. It returns the archive's SHA-256 digest.
+func installTask(version, assetName, archiveURL, taskPath string) (string, error) {
+	checksums, _, err := download(fmt.Sprintf("%s/%s/%s", releasesBaseURL, version, checksumsAsset))
+	if err != nil {
+		return "", err
+	}
+
+	expected, err := releaseChecksum(checksums, assetName)
+	if err != nil {
+		return "", err
+	}
+
+	data, checksum, err := download(archiveURL)
+	if err != nil {
+		return "", err
+	}
+
+	if checksum != expected {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", assetName, expected, checksum)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(taskPath), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create bin directory: %w", err)
+	}
+
+	if err := extractBinary(data, "task", taskPath); err != nil {
+		return "", fmt.Errorf("failed to extract task archive: %w", err)
+	}
+
+	return checksum, nil
+}
+
+// releaseChecksum finds assetName in a checksums file in sha256sum format.
+func releaseChecksum(checksums []byte, assetName string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == assetName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", checksumsAsset, err)
+	}
+
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, assetName)
+}
+
+func download(url string) ([]byte, string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download of %s returned status %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// extractBinary writes the archive entry called name to dest.
+func extractBinary(data []byte, name, dest string) error {
+	gzReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("archive has no %s binary", name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || filepath.Clean(header.Name) != name {
+			continue
+		}
+
+		file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", dest, err)
+		}
+
+		if _, err := io.Copy(file, tarReader); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+
+		return file.Close()
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+)
+
+// taskSBOM builds CycloneDX, SPDX and Syft documents for the task binary,
+// recording the release version, the archive it came from (with the
+// archive's digest in the purl) and the digest of the extracted binary.
+func taskSBOM(info packit.BuildpackInfo, version, assetName, archiveChecksum, binarySHA256, archiveURL string) (packit.SBOMFormats, error) {
+	purl := fmt.Sprintf("pkg:github/go-task/task@%s?download_url=%s&file_name=%s&checksum=%s",
+		url.PathEscape(version), url.QueryEscape(archiveURL), url.QueryEscape(assetName), url.QueryEscape("sha256:"+archiveChecksum))
+
+	subject := sbom.Component{
+		Name:     "task",
+		Version:  version,
+		PURL:     purl,
+		SHA256:   binarySHA256,
+		Type:     "binary",
+		Location: "bin/task",
+	}
+
+	return sbom.Formats(info, subject, nil)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const processesEnv = "BP_TASKFILE_PROCESSES"
+
+// processTypeUnsafe matches what may not appear in a CNB process type. Task
+// namespaces ("docker:build") become "docker-build".
+var processTypeUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+type taskList struct {
+	Tasks []struct {
+		Name string `json:"name"`
+	} `json:"tasks"`
+}
+
+// taskProcesses registers a launch process running "task <name>" for each
+// task the Taskfile lists, or only for the selected ones (taskfile.processes
+// or BP_TASKFILE_PROCESSES, comma-separated). None of them is the default, so
+// the runtime buildpack keeps its dev process; it does pick up a task called
+// web as the upstream web process.
+func taskProcesses(taskPath, workingDir string, selected []string) ([]packit.DirectProcess, error) {
+	cmd := exec.Command(taskPath, "--list-all", "--json")
+	cmd.Dir = workingDir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var list taskList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse task list: %w", err)
+	}
+
+	var names []string
+	for _, task := range list.Tasks {
+		names = append(names, task.Name)
+	}
+
+	if len(selected) > 0 {
+		for _, name := range selected {
+			if !slices.Contains(names, name) {
+				return nil, fmt.Errorf("processes: the Taskfile has no task %q", name)
+			}
+		}
+		names = selected
+	}
+
+	var processes []packit.DirectProcess
+	types := map[string]string{}
+	for _, name := range names {
+		processType := processTypeUnsafe.ReplaceAllString(name, "-")
+		if other, ok := types[processType]; ok {
+			return nil, fmt.Errorf("tasks %q and %q both map to process type %q", other, name, processType)
+		}
+		types[processType] = name
+
+		processes = append(processes, packit.DirectProcess{
+			Type:    processType,
+			Command: []string{"task", name},
+		})
+	}
+
+	return processes, nil
+}
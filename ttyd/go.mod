@@ -2,10 +2,15 @@ module github.com/supervise-dev/buildpack/ttyd
 
 go 1.25.1
 
-require github.com/paketo-buildpacks/packit/v2 v2.25.1
+require (
+	github.com/paketo-buildpacks/packit/v2 v2.25.1
+	github.com/supervise-dev/buildpack/internal v0.0.0
+)
 
 require (
 	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/Masterminds/semver/v3 v3.4.0 // indirect
 	github.com/pelletier/go-toml v1.9.5 // indirect
 )
+
+replace github.com/supervise-dev/buildpack/internal => ../internal
@@ -9,15 +9,18 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/supervise"
 )
 
 const (
 	layerName       = "ttyd"
 	defaultVersion  = "1.7.7"
 	releasesBaseURL = "https://github.com/tsl0922/ttyd/releases/download"
+	versionEnv      = "TTYD_VERSION"
 )
 
 var assetMap = map[string]string{
@@ -29,32 +32,103 @@ func main() {
 	packit.Run(detect, build)
 }
 
-func detect(packit.DetectContext) (packit.DetectResult, error) {
-	return packit.DetectResult{}, nil
+func detect(context packit.DetectContext) (packit.DetectResult, error) {
+	config, settings, _, err := loadSettings(context.WorkingDir)
+	if err != nil {
+		return packit.DetectResult{}, err
+	}
+
+	if supervise.ComponentDisabled(config.Disable, layerName) {
+		return packit.DetectResult{}, packit.Fail.WithMessage("ttyd is disabled by the Supervise config or %s", supervise.DisableEnv)
+	}
+
+	plan := packit.BuildPlan{
+		Provides: []packit.BuildPlanProvision{
+			{Name: layerName},
+		},
+	}
+
+	// Building from source needs pkgx for the toolchain.
+	if settings.BuildFromSource {
+		plan.Requires = []packit.BuildPlanRequirement{
+			{Name: "pkgx", Metadata: map[string]interface{}{"build": true}},
+		}
+	}
+
+	return packit.DetectResult{Plan: plan}, nil
+}
+
+// loadSettings returns the Supervise config with the ttyd section completed
+// by the defaults and the TTYD_VERSION, BP_TTYD_BUILD_FROM_SOURCE,
+// BP_TTYD_SOURCE_TOOLCHAIN and BP_TTYD_SOURCE_SHA256 overrides.
+func loadSettings(workingDir string) (supervise.Config, supervise.TTYDSettings, string, error) {
+	config, source, err := supervise.LoadConfig(workingDir)
+	if err != nil {
+		return supervise.Config{}, supervise.TTYDSettings{}, "", err
+	}
+
+	settings := config.TTYD
+	if settings.Version == "" {
+		settings.Version = defaultVersion
+	}
+	if settings.SourceToolchain == nil {
+		settings.SourceToolchain = defaultSourceToolchain
+	}
+
+	supervise.OverrideString(versionEnv, &settings.Version)
+	if err := supervise.OverrideBool(buildFromSourceEnv, &settings.BuildFromSource); err != nil {
+		return supervise.Config{}, supervise.TTYDSettings{}, "", err
+	}
+	if packages := strings.Fields(os.Getenv(sourceToolchainEnv)); len(packages) > 0 {
+		settings.SourceToolchain = packages
+	}
+	supervise.OverrideString(sourceSHA256Env, &settings.SourceSHA256)
+
+	return config, settings, source, nil
 }
 
 func build(context packit.BuildContext) (packit.BuildResult, error) {
 	osName := runtime.GOOS
 	arch := runtime.GOARCH
 
-	assetKey := fmt.Sprintf("%s/%s", osName, arch)
-	assetName, ok := assetMap[assetKey]
-	if !ok {
-		return packit.BuildResult{}, fmt.Errorf("unsupported platform %s", assetKey)
+	_, settings, source, err := loadSettings(context.WorkingDir)
+	if err != nil {
+		return packit.BuildResult{}, err
 	}
 
-	version := strings.TrimSpace(os.Getenv("TTYD_VERSION"))
-	if version == "" {
-		version = defaultVersion
-	}
+	supervise.PrintConfig(source, [][2]string{
+		{"ttyd.version", settings.Version},
+		{"ttyd.build_from_source", strconv.FormatBool(settings.BuildFromSource)},
+		{"ttyd.source_toolchain", strings.Join(settings.SourceToolchain, " ")},
+		{"ttyd.source_sha256", settings.SourceSHA256},
+	})
 
-	archiveURL := fmt.Sprintf("%s/%s/%s", releasesBaseURL, version, assetName)
+	version := settings.Version
 
 	layer, err := context.Layers.Get(layerName)
 	if err != nil {
 		return packit.BuildResult{}, fmt.Errorf("failed to get %s layer: %w", layerName, err)
 	}
 
+	if settings.BuildFromSource {
+		layer, err = sourceLayer(context, layer, settings)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		return packit.BuildResult{
+			Layers: []packit.Layer{layer},
+		}, nil
+	}
+
+	assetKey := fmt.Sprintf("%s/%s", osName, arch)
+	assetName, ok := assetMap[assetKey]
+	if !ok {
+		return packit.BuildResult{}, fmt.Errorf("unsupported platform %s, set %s=true to build ttyd from source", assetKey, buildFromSourceEnv)
+	}
+
+	archiveURL := fmt.Sprintf("%s/%s/%s", releasesBaseURL, version, assetName)
+
 	layer, err = layer.Reset()
 	if err != nil {
 		return packit.BuildResult{}, fmt.Errorf("failed to reset %s layer: %w", layer.Name, err)
@@ -67,7 +141,7 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 
 	binaryPath := filepath.Join(binDir, "ttyd")
 
-	data, checksum, err := fetchBinary(archiveURL)
+	data, checksum, err := fetch(archiveURL)
 	if err != nil {
 		return packit.BuildResult{}, err
 	}
@@ -76,6 +150,11 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 		return packit.BuildResult{}, fmt.Errorf("failed to write ttyd binary: %w", err)
 	}
 
+	layer.SBOM, err = ttydSBOM(context.BuildpackInfo, version, assetName, archiveURL, checksum, "", nil)
+	if err != nil {
+		return packit.BuildResult{}, err
+	}
+
 	layer.Launch = true
 	layer.Build = true
 	layer.Cache = true
@@ -95,7 +174,9 @@ func build(context packit.BuildContext) (packit.BuildResult, error) {
 	}, nil
 }
 
-func fetchBinary(url string) ([]byte, string, error) {
+// fetch downloads a ttyd release asset or source archive and returns it
+// with its SHA-256 digest.
+func fetch(url string) ([]byte, string, error) {
 	resp, err := http.Get(url)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to download ttyd from %s: %w", url, err)
@@ -108,7 +189,7 @@ func fetchBinary(url string) ([]byte, string, error) {
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read ttyd binary: %w", err)
+		return nil, "", fmt.Errorf("failed to read ttyd download: %w", err)
 	}
 
 	sum := sha256.Sum256(data)
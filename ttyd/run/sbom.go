@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+)
+
+// ttydSBOM builds CycloneDX, SPDX and Syft documents for the ttyd binary,
+// recording the release version, the asset it came from and the binary's
+// digest. A binary built from source also records the digest of the source
+// archive and the shared libraries bundled next to it.
+func ttydSBOM(info packit.BuildpackInfo, version, assetName, archiveURL, binarySHA256, sourceDigest string, libraries []sbom.Component) (packit.SBOMFormats, error) {
+	purl := fmt.Sprintf("pkg:github/tsl0922/ttyd@%s?download_url=%s&file_name=%s",
+		url.PathEscape(version), url.QueryEscape(archiveURL), url.QueryEscape(assetName))
+	if sourceDigest != "" {
+		purl += "&checksum=" + url.QueryEscape("sha256:"+sourceDigest)
+	}
+
+	subject := sbom.Component{
+		Name:     "ttyd",
+		Version:  version,
+		PURL:     purl,
+		SHA256:   binarySHA256,
+		Type:     "binary",
+		Location: "bin/ttyd",
+	}
+
+	return sbom.Formats(info, subject, libraries)
+}
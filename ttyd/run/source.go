@@ -0,0 +1,386 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/supervise-dev/buildpack/internal/sbom"
+	"github.com/supervise-dev/buildpack/internal/supervise"
+)
+
+const (
+	buildFromSourceEnv = "BP_TTYD_BUILD_FROM_SOURCE"
+	sourceToolchainEnv = "BP_TTYD_SOURCE_TOOLCHAIN"
+	sourceSHA256Env    = "BP_TTYD_SOURCE_SHA256"
+	sourceBaseURL      = "https://github.com/tsl0922/ttyd/archive/refs/tags"
+)
+
+// defaultSourceToolchain are the pkgx packages ttyd is configured and
+// compiled with. ttyd.source_toolchain or BP_TTYD_SOURCE_TOOLCHAIN (space
+// separated) replaces the list, e.g. to pin versions ("cmake.org@3.29
+// libuv.org@1.48 ...").
+var defaultSourceToolchain = []string{
+	"cmake.org",
+	"gnu.org/make",
+	"llvm.org",
+	"libwebsockets.org",
+	"github.com/json-c/json-c",
+	"libuv.org",
+	"zlib.net",
+	"openssl.org",
+}
+
+// sourceLayer fills the ttyd layer with a binary compiled from the tagged
+// source. The build is keyed on the source archive: it is reused while the
+// archive's digest, the toolchain and the buildpack version match the layer
+// metadata. With ttyd.source_sha256 (or BP_TTYD_SOURCE_SHA256) the pinned
+// digest is compared without downloading and a downloaded archive must match
+// it; otherwise the archive's ETag stands in for the digest, so a tag that
+// was moved to another commit is rebuilt.
+func sourceLayer(context packit.BuildContext, layer packit.Layer, settings supervise.TTYDSettings) (packit.Layer, error) {
+	version := settings.Version
+	sourceURL := fmt.Sprintf("%s/%s.tar.gz", sourceBaseURL, version)
+	toolchain := strings.Join(settings.SourceToolchain, " ")
+	pinned := strings.ToLower(settings.SourceSHA256)
+
+	etag := ""
+	if pinned == "" {
+		var err error
+		etag, err = sourceETag(sourceURL)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	binaryPath := filepath.Join(layer.Path, "bin", "ttyd")
+	_, statErr := os.Stat(binaryPath)
+
+	digest, _ := layer.Metadata["source_digest"].(string)
+	libraries, _ := layer.Metadata["libraries"].(map[string]interface{})
+	cached := digest != "" &&
+		layer.Metadata["build_from_source"] == true &&
+		layer.Metadata["version"] == version &&
+		layer.Metadata["toolchain"] == toolchain &&
+		layer.Metadata["buildpack_version"] == context.BuildpackInfo.Version &&
+		statErr == nil
+	if pinned != "" {
+		cached = cached && digest == pinned
+	} else {
+		cached = cached && etag != "" && layer.Metadata["source_etag"] == etag
+	}
+
+	if cached {
+		fmt.Printf("Reusing ttyd %s built from source %s\n", version, digest)
+	} else {
+		data, sourceDigest, err := fetch(sourceURL)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+		if pinned != "" && sourceDigest != pinned {
+			return packit.Layer{}, fmt.Errorf("ttyd %s source archive has digest %s, but %s pins %s", version, sourceDigest, sourceSHA256Env, pinned)
+		}
+		digest = sourceDigest
+
+		layer, err = layer.Reset()
+		if err != nil {
+			return packit.Layer{}, fmt.Errorf("failed to reset %s layer: %w", layerName, err)
+		}
+
+		sourceDir, err := os.MkdirTemp("", "ttyd-source")
+		if err != nil {
+			return packit.Layer{}, fmt.Errorf("failed to create source directory: %w", err)
+		}
+		defer os.RemoveAll(sourceDir)
+
+		if err := extractSource(data, sourceDir); err != nil {
+			return packit.Layer{}, fmt.Errorf("failed to extract ttyd source: %w", err)
+		}
+
+		fmt.Printf("Building ttyd %s from source with %s\n", version, strings.Join(settings.SourceToolchain, ", "))
+		bundled, err := compileSource(sourceDir, layer.Path, settings.SourceToolchain)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		libraries = map[string]interface{}{}
+		for _, library := range bundled {
+			libraries[library.File] = library.Package + "@" + library.Version
+		}
+	}
+
+	binarySHA256, err := sbom.FileSHA256(binaryPath)
+	if err != nil {
+		return packit.Layer{}, fmt.Errorf("failed to hash ttyd binary: %w", err)
+	}
+
+	var components []sbom.Component
+	for _, file := range slices.Sorted(maps.Keys(libraries)) {
+		pkg, _ := libraries[file].(string)
+		name, libraryVersion, _ := strings.Cut(pkg, "@")
+
+		location := filepath.Join("lib", file)
+		checksum, err := sbom.FileSHA256(filepath.Join(layer.Path, location))
+		if err != nil {
+			return packit.Layer{}, fmt.Errorf("failed to hash bundled library %s: %w", file, err)
+		}
+
+		components = append(components, sbom.Component{
+			Name:     name,
+			Version:  libraryVersion,
+			PURL:     fmt.Sprintf("pkg:generic/%s@%s?file_name=%s", url.PathEscape(name), url.PathEscape(libraryVersion), url.QueryEscape(file)),
+			SHA256:   checksum,
+			Type:     "binary",
+			Location: location,
+		})
+	}
+
+	formats, err := ttydSBOM(context.BuildpackInfo, version, fmt.Sprintf("ttyd-%s.tar.gz", version), sourceURL, binarySHA256, digest, components)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+	layer.SBOM = formats
+
+	layer.Launch = true
+	layer.Build = true
+	layer.Cache = true
+
+	layer.Metadata = map[string]interface{}{
+		"build_from_source": true,
+		"source_digest":     digest,
+		"source_etag":       etag,
+		"source_uri":        sourceURL,
+		"libraries":         libraries,
+		"toolchain":         toolchain,
+		"version":           version,
+		"os":                runtime.GOOS,
+		"arch":              runtime.GOARCH,
+		"buildpack_version": context.BuildpackInfo.Version,
+	}
+
+	return layer, nil
+}
+
+// sourceETag asks for the ETag of the source archive without downloading
+// it. An empty ETag means the server did not send one, and the source is
+// built again.
+func sourceETag(sourceURL string) (string, error) {
+	resp, err := http.Head(sourceURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to check ttyd source at %s: %w", sourceURL, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ttyd source check returned status %s", resp.Status)
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+// compileSource builds the ttyd source tree in sourceDir with the pkgx
+// toolchain and installs it into prefix. The shared libraries ttyd links
+// against live in the pkgx cache, which is not part of the image, so they
+// are copied to prefix/lib and found there through the binary's rpath.
+func compileSource(sourceDir, prefix string, toolchain []string) ([]bundledLibrary, error) {
+	buildDir := filepath.Join(sourceDir, "build")
+
+	steps := [][]string{
+		{
+			"cmake", "-S", sourceDir, "-B", buildDir,
+			"-DCMAKE_BUILD_TYPE=Release",
+			"-DCMAKE_INSTALL_PREFIX=" + prefix,
+			"-DCMAKE_INSTALL_RPATH=$ORIGIN/../lib",
+			"-DCMAKE_INSTALL_RPATH_USE_LINK_PATH=ON",
+			// DT_RPATH rather than DT_RUNPATH, so the bundled copies are
+			// also used for the libraries' own dependencies.
+			"-DCMAKE_EXE_LINKER_FLAGS=-Wl,--disable-new-dtags",
+		},
+		{"cmake", "--build", buildDir, "--parallel"},
+		{"cmake", "--install", buildDir},
+	}
+
+	for _, step := range steps {
+		cmd := exec.Command("pkgx", toolchainArgs(toolchain, step)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", strings.Join(step[:2], " "), err)
+		}
+	}
+
+	return bundleLibraries(filepath.Join(prefix, "bin", "ttyd"), filepath.Join(prefix, "lib"), toolchain)
+}
+
+func toolchainArgs(toolchain, command []string) []string {
+	args := make([]string, 0, len(toolchain)+len(command)+1)
+	for _, pkg := range toolchain {
+		args = append(args, "+"+pkg)
+	}
+	args = append(args, "--")
+
+	return append(args, command...)
+}
+
+// bundledLibrary is a shared library copied from the pkgx cache, with the
+// pkgx package and version it came from.
+type bundledLibrary struct {
+	File    string
+	Package string
+	Version string
+}
+
+// bundleLibraries copies the shared libraries binary resolves from the pkgx
+// cache into libDir. Libraries of the base image are left to the image.
+func bundleLibraries(binary, libDir string, toolchain []string) ([]bundledLibrary, error) {
+	output, err := exec.Command("pkgx", toolchainArgs(toolchain, []string{"ldd", binary})...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ttyd libraries: %w", err)
+	}
+
+	pkgxDir := os.Getenv("PKGX_DIR")
+	if pkgxDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate pkgx cache: %w", err)
+		}
+		pkgxDir = filepath.Join(home, ".pkgx")
+	}
+
+	if err := os.MkdirAll(libDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lib directory: %w", err)
+	}
+
+	var libraries []bundledLibrary
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// e.g. "libuv.so.1 => /root/.pkgx/libuv.org/v1.48.0/lib/libuv.so.1 (0x...)"
+		name, resolved, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " => ")
+		if !ok {
+			continue
+		}
+		resolved, _, _ = strings.Cut(resolved, " (")
+
+		relative, ok := strings.CutPrefix(resolved, pkgxDir+string(os.PathSeparator))
+		if !ok {
+			continue
+		}
+
+		data, err := os.ReadFile(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read library %s: %w", resolved, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(libDir, name), data, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to bundle library %s: %w", name, err)
+		}
+
+		libraries = append(libraries, pkgxLibrary(name, relative))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ttyd libraries: %w", err)
+	}
+
+	return libraries, nil
+}
+
+// pkgxLibrary names the package of a library from its path in the pkgx
+// cache, e.g. "libuv.org/v1.48.0/lib/libuv.so.1" or
+// "github.com/json-c/json-c/v0.17.0/lib/libjson-c.so.5".
+func pkgxLibrary(file, relative string) bundledLibrary {
+	parts := strings.Split(filepath.ToSlash(relative), "/")
+	for i, part := range parts {
+		if i > 0 && strings.HasPrefix(part, "v") && len(part) > 1 && part[1] >= '0' && part[1] <= '9' {
+			return bundledLibrary{File: file, Package: strings.Join(parts[:i], "/"), Version: part[1:]}
+		}
+	}
+
+	return bundledLibrary{File: file, Package: file}
+}
+
+// extractSource unpacks a GitHub source archive into dest, dropping the
+// archive's top-level "ttyd-<version>" directory.
+func extractSource(data []byte, dest string) error {
+	gzReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		_, name, ok := strings.Cut(header.Name, "/")
+		if !ok || name == "" {
+			continue
+		}
+
+		target := filepath.Join(dest, name)
+		if err := ensureWithinDir(dest, target); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", target, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil && !errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("failed to create symlink %s -> %s: %w", target, header.Linkname, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", target, err)
+			}
+
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			if err != nil {
+				return fmt.Errorf("failed to create file %s: %w", target, err)
+			}
+
+			if _, err := io.Copy(file, tarReader); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to copy file %s: %w", target, err)
+			}
+
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to close file %s: %w", target, err)
+			}
+		}
+	}
+}
+
+func ensureWithinDir(root, target string) error {
+	root = filepath.Clean(root)
+	target = filepath.Clean(target)
+
+	if !strings.HasPrefix(target, root+string(os.PathSeparator)) && target != root {
+		return fmt.Errorf("archive entry escapes destination: %s", target)
+	}
+
+	return nil
+}
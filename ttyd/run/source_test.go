@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestPkgxLibrary(t *testing.T) {
+	for relative, want := range map[string]bundledLibrary{
+		"libuv.org/v1.48.0/lib/libuv.so.1":                    {File: "libuv.so.1", Package: "libuv.org", Version: "1.48.0"},
+		"github.com/json-c/json-c/v0.17.0/lib/libjson-c.so.5": {File: "libjson-c.so.5", Package: "github.com/json-c/json-c", Version: "0.17.0"},
+		"openssl.org/v3.3.1/lib/libssl.so.3":                  {File: "libssl.so.3", Package: "openssl.org", Version: "3.3.1"},
+		"unversioned/lib/libfoo.so":                           {File: "libfoo.so", Package: "libfoo.so"},
+	} {
+		got := pkgxLibrary(want.File, relative)
+		if got != want {
+			t.Errorf("pkgxLibrary(%q) = %+v, want %+v", relative, got, want)
+		}
+	}
+}